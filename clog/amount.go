@@ -0,0 +1,103 @@
+package clog
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/shopspring/decimal"
+
+	"github.com/wawafc/go-utils/amount"
+	"github.com/wawafc/go-utils/amount2"
+	"github.com/wawafc/go-utils/amountfast"
+	"github.com/wawafc/go-utils/money"
+)
+
+// ensure we always implement zerolog.LogObjectMarshaler
+var _ zerolog.LogObjectMarshaler = amountField{}
+
+var (
+	// CurrencySuffix of the key of the currency of an amount.
+	CurrencySuffix = "Currency"
+	// ScaledSuffix of the key of the scaled integer of an amount.
+	ScaledSuffix = "Scaled"
+)
+
+// AmountOption adds a field next to an amount.
+type AmountOption func(*amountField)
+
+// WithCurrency logs the currency code of the amount under the key of the
+// amount with CurrencySuffix.
+func WithCurrency(code string) AmountOption {
+	return func(f *amountField) {
+		f.currency = code
+	}
+}
+
+// WithScaled logs the amount as an integer of 10^-places units, e.g. the
+// satang of 2 places, under the key of the amount with ScaledSuffix. The
+// integer is a decimal string whatever its size, so it keeps its precision
+// and type in every event. Amounts finer than the unit are logged without
+// it.
+func WithScaled(places int32) AmountOption {
+	return func(f *amountField) {
+		f.scaled = true
+		f.places = places
+	}
+}
+
+// amountField embeds an amount in an event as its exact decimal string.
+type amountField struct {
+	key      string
+	value    string
+	currency string
+	scaled   bool
+	places   int32
+}
+
+// Amount logs v under key as its exact decimal string, where Interface logs
+// a JSON number that log pipelines parse as a float.
+//
+//	log.Info().EmbedObject(clog.Amount("balance", v, clog.WithCurrency("THB"), clog.WithScaled(2))).Msg("debit")
+//	// {"balance":"12345678901234567.89","balanceCurrency":"THB","balanceScaled":"1234567890123456789",...}
+func Amount(key string, v amount.Value, opts ...AmountOption) zerolog.LogObjectMarshaler {
+	return newAmountField(key, v.String(), opts)
+}
+
+// AmountV2 is Amount for the values of amount2.
+func AmountV2(key string, v amount2.Value, opts ...AmountOption) zerolog.LogObjectMarshaler {
+	return newAmountField(key, v.String(), opts)
+}
+
+// AmountFast is Amount for the values of amountfast.
+func AmountFast(key string, v amountfast.Value, opts ...AmountOption) zerolog.LogObjectMarshaler {
+	return newAmountField(key, v.String(), opts)
+}
+
+// Money is Amount for money.Money.
+func Money(key string, v money.Money, opts ...AmountOption) zerolog.LogObjectMarshaler {
+	return newAmountField(key, v.String(), opts)
+}
+
+func newAmountField(key, value string, opts []AmountOption) amountField {
+	f := amountField{key: key, value: value}
+	for _, opt := range opts {
+		opt(&f)
+	}
+	return f
+}
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler.
+func (f amountField) MarshalZerologObject(e *zerolog.Event) {
+	e.Str(f.key, f.value)
+	if f.currency != "" {
+		e.Str(f.key+CurrencySuffix, f.currency)
+	}
+	if !f.scaled {
+		return
+	}
+	d, err := decimal.NewFromString(f.value)
+	if err != nil {
+		return
+	}
+	if scaled := d.Shift(f.places); scaled.IsInteger() {
+		e.Str(f.key+ScaledSuffix, scaled.String())
+	}
+}
@@ -0,0 +1,35 @@
+package clog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/wawafc/go-utils/amount"
+	"github.com/wawafc/go-utils/amountfast"
+	"github.com/wawafc/go-utils/money"
+)
+
+func TestAmount(t *testing.T) {
+	big, _ := amount.FromString("12345678901234567.89")
+	// finer than the unit, so logged without its scaled integer
+	m, _ := money.NewMoneyFromString("98765432109876543210.5")
+	fast, _ := amountfast.FromString("1.000001")
+
+	var buf bytes.Buffer
+	lg := zerolog.New(&buf)
+	lg.Info().
+		EmbedObject(Amount("balance", big, WithCurrency("THB"), WithScaled(2))).
+		EmbedObject(Money("limit", m, WithScaled(0))).
+		EmbedObject(AmountFast("rate", fast, WithScaled(6))).
+		Send()
+
+	want := `{"level":"info","balance":"12345678901234567.89","balanceCurrency":"THB","balanceScaled":"1234567890123456789",` +
+		`"limit":"98765432109876543210.5",` +
+		`"rate":"1.000001","rateScaled":"1000001"}`
+	if got := strings.TrimSpace(buf.String()); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
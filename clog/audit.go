@@ -0,0 +1,367 @@
+package clog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// AuditSeqField key of the audit sequence number.
+	AuditSeqField = "auditSeq"
+	// AuditPrevField key of the hash of the previous audit line.
+	AuditPrevField = "auditPrev"
+	// AuditHashField key of the hash of the audit line.
+	AuditHashField = "auditHash"
+)
+
+// auditGenesis is the previous hash of the first line of a chain.
+var auditGenesis = strings.Repeat("0", sha256.Size*2)
+
+// ensure we always implement io.WriteCloser
+var _ io.WriteCloser = (*AuditWriter)(nil)
+
+// AuditWriter is a tamper-evident file sink. Every line gets a sequence
+// number and a SHA-256 hash chained to the previous line:
+//
+//	{..., "auditSeq": 42, "auditPrev": "<hash of line 41>", "auditHash": "<hash of line 42>"}
+//
+// The chain carries on across rotated files, so each file starts from the
+// last hash of its predecessor, and across restarts, as the last line is read
+// back from the log directory before the first write. Use VerifyAudit or the
+// clogaudit command to check a chain.
+type AuditWriter struct {
+	out    io.WriteCloser
+	dir    string
+	prefix string
+	keys   KeyProvider
+
+	mu      sync.Mutex
+	resumed bool
+	seq     uint64
+	hash    string
+}
+
+func NewAuditLog(cf ConfigFile) *AuditWriter {
+	l := NewLogFile(cf)
+	a := &AuditWriter{out: l, keys: cf.KeyProvider}
+	if cf.EnableTimeKey {
+		a.dir = cf.Path
+	} else {
+		name := l.filename()
+		a.dir = filepath.Dir(name)
+		a.prefix = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+	return a
+}
+
+// Write implements io.Writer, appending the chain fields to the event p.
+func (a *AuditWriter) Write(p []byte) (n int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.resumed {
+		if err := a.resume(); err != nil {
+			return 0, err
+		}
+		a.resumed = true
+	}
+
+	body := bytes.TrimRight(p, "\n")
+	if len(body) < 2 || body[len(body)-1] != '}' {
+		return 0, errors.New("audit event is not a JSON object")
+	}
+	body = body[:len(body)-1]
+
+	seq := a.seq + 1
+	hash := auditHash(a.hash, seq, body)
+	line := make([]byte, 0, len(body)+200)
+	line = append(line, body...)
+	if len(body) > 1 {
+		line = append(line, ',')
+	}
+	line = append(line, fmt.Sprintf(`"%s":%d,"%s":"%s","%s":"%s"}`+"\n",
+		AuditSeqField, seq, AuditPrevField, a.hash, AuditHashField, hash)...)
+
+	if _, err := a.out.Write(line); err != nil {
+		return 0, err
+	}
+	a.seq = seq
+	a.hash = hash
+	return len(p), nil
+}
+
+// Close implements io.Closer, and closes the current audit file.
+func (a *AuditWriter) Close() error {
+	return a.out.Close()
+}
+
+// Shutdown closes the file sink, waiting for its compression until ctx is
+// done.
+func (a *AuditWriter) Shutdown(ctx context.Context) error {
+	return shutdownSink(ctx, a.out)
+}
+
+// resume picks the chain up from the newest audit line on disk.
+func (a *AuditWriter) resume() error {
+	a.seq, a.hash = 0, auditGenesis
+
+	files, err := auditFiles(a.dir, a.prefix, a.keys)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	// a file just opened by rotation may not hold any line yet
+	for i := len(files) - 1; i >= 0; i-- {
+		last, err := readAuditRecords(files[i], a.keys, func(auditRecord) {})
+		if err != nil {
+			return fmt.Errorf("cannot resume audit chain: %s", err)
+		}
+		if last != nil {
+			a.seq, a.hash = last.Seq, last.Hash
+			return nil
+		}
+	}
+	return nil
+}
+
+// AuditIssue is a break of an audit chain found by VerifyAudit.
+type AuditIssue struct {
+	File    string
+	Line    int
+	Seq     uint64
+	Problem string
+}
+
+func (i AuditIssue) String() string {
+	return fmt.Sprintf("%s:%d: seq %d: %s", i.File, i.Line, i.Seq, i.Problem)
+}
+
+// AuditReport is the result of VerifyAudit.
+type AuditReport struct {
+	Files    int
+	Records  uint64
+	FirstSeq uint64
+	LastSeq  uint64
+	LastHash string
+	Issues   []AuditIssue
+}
+
+// OK reports whether the chain verified without issues.
+func (r AuditReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// VerifyAudit checks the audit chain of files, read in the given order. Plain
+// and gzipped files are accepted. It reports edited lines, gaps in the
+// sequence and lines or files out of order.
+func VerifyAudit(files ...string) (AuditReport, error) {
+	return VerifyAuditKeys(nil, files...)
+}
+
+// VerifyAuditKeys is VerifyAudit for encrypted audit files.
+func VerifyAuditKeys(keys KeyProvider, files ...string) (AuditReport, error) {
+	report := AuditReport{}
+	var prev *auditRecord
+
+	for _, name := range files {
+		report.Files++
+		_, err := readAuditRecords(name, keys, func(r auditRecord) {
+			report.Records++
+			if r.Problem != "" {
+				report.Issues = append(report.Issues, AuditIssue{name, r.Line, r.Seq, r.Problem})
+				// an edited line keeps its place in the chain, a malformed one has none
+				if r.Seq == 0 {
+					return
+				}
+			} else if prev != nil {
+				switch {
+				case r.Seq > prev.Seq+1:
+					report.Issues = append(report.Issues, AuditIssue{name, r.Line, r.Seq,
+						fmt.Sprintf("gap: %d lines missing after seq %d", r.Seq-prev.Seq-1, prev.Seq)})
+				case r.Seq <= prev.Seq:
+					report.Issues = append(report.Issues, AuditIssue{name, r.Line, r.Seq,
+						fmt.Sprintf("out of order: follows seq %d", prev.Seq)})
+				case r.Prev != prev.Hash:
+					report.Issues = append(report.Issues, AuditIssue{name, r.Line, r.Seq,
+						"chain broken: previous hash does not match"})
+				}
+			}
+			if prev == nil {
+				report.FirstSeq = r.Seq
+			}
+			report.LastSeq, report.LastHash = r.Seq, r.Hash
+			rec := r
+			prev = &rec
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// VerifyAuditDir checks the audit chain of every log file in dir. Files are
+// ordered by the sequence number of their first line, so a missing file shows
+// up as a gap. keys may be nil when the files are not encrypted.
+func VerifyAuditDir(dir string, keys KeyProvider) (AuditReport, error) {
+	files, err := auditFiles(dir, "", keys)
+	if err != nil {
+		return AuditReport{}, err
+	}
+	return VerifyAuditKeys(keys, files...)
+}
+
+// auditRecord is one line of an audit file.
+type auditRecord struct {
+	Line    int
+	Seq     uint64
+	Prev    string
+	Hash    string
+	Problem string
+}
+
+// auditFiles returns the log files in dir starting with prefix, ordered by
+// the sequence number of their first audit line.
+func auditFiles(dir, prefix string, keys KeyProvider) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type firstSeq struct {
+		name string
+		seq  uint64
+	}
+	var files []firstSeq
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !strings.HasSuffix(name, ".log") && !strings.HasSuffix(name, ".log"+compressSuffix) {
+			continue
+		}
+		name = filepath.Join(dir, name)
+		seq, err := firstAuditSeq(name, keys)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, firstSeq{name, seq})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].seq < files[j].seq
+	})
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.name)
+	}
+	return names, nil
+}
+
+// firstAuditSeq returns the sequence number of the first audit line of name.
+func firstAuditSeq(name string, keys KeyProvider) (uint64, error) {
+	rc, err := OpenLogFile(name, keys)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	line, err := bufio.NewReader(rc).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	// an edited first line still tells where the file belongs
+	return parseAuditLine(line).Seq, nil
+}
+
+// readAuditRecords calls fn for every line of name and returns the last valid
+// record.
+func readAuditRecords(name string, keys KeyProvider, fn func(auditRecord)) (*auditRecord, error) {
+	rc, err := OpenLogFile(name, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var last *auditRecord
+	br := bufio.NewReader(rc)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			r := parseAuditLine(line)
+			r.Line = n
+			if r.Problem == "" {
+				rec := r
+				last = &rec
+			}
+			fn(r)
+		}
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return last, fmt.Errorf("%s: %s", name, err)
+		}
+	}
+}
+
+// parseAuditLine splits the chain fields off line and checks its hash.
+func parseAuditLine(line []byte) auditRecord {
+	line = bytes.TrimRight(line, "\r\n")
+	idx := bytes.LastIndex(line, []byte(`"`+AuditSeqField+`":`))
+	if idx < 1 || line[len(line)-1] != '}' {
+		return auditRecord{Problem: "malformed: audit fields missing"}
+	}
+
+	body := line[:idx]
+	if body[len(body)-1] == ',' {
+		body = body[:len(body)-1]
+	}
+
+	var r struct {
+		Seq  uint64 `json:"auditSeq"`
+		Prev string `json:"auditPrev"`
+		Hash string `json:"auditHash"`
+	}
+	tail := append([]byte{'{'}, line[idx:]...)
+	if err := json.Unmarshal(tail, &r); err != nil {
+		return auditRecord{Problem: "malformed: " + err.Error()}
+	}
+
+	rec := auditRecord{Seq: r.Seq, Prev: r.Prev, Hash: r.Hash}
+	if auditHash(r.Prev, r.Seq, body) != r.Hash {
+		rec.Problem = "edited: hash does not match content"
+	}
+	return rec
+}
+
+// auditHash chains body to prev.
+func auditHash(prev string, seq uint64, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(prev))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(strconv.FormatUint(seq, 10)))
+	h.Write([]byte{'\n'})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
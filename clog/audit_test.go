@@ -0,0 +1,124 @@
+package clog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// fakeClock makes every call to currentTime one second later, so rotated
+// backups get distinct names.
+func fakeClock(t *testing.T) {
+	t.Helper()
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	prev := currentTime
+	currentTime = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	t.Cleanup(func() { currentTime = prev })
+}
+
+func writeAudit(t *testing.T, dir string, from, to int) {
+	t.Helper()
+	a := NewAuditLog(ConfigFile{Filename: filepath.Join(dir, "audit.log"), MaxSize: "600"})
+	lg := zerolog.New(a).With().Timestamp().Logger()
+	for i := from; i < to; i++ {
+		lg.Info().Int("txn", i).Msg("transfer")
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditWriter_Chain(t *testing.T) {
+	fakeClock(t)
+	dir := t.TempDir()
+
+	writeAudit(t, dir, 0, 10)
+	// a restart picks the chain up from the last line on disk
+	writeAudit(t, dir, 10, 20)
+
+	files, err := auditFiles(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 3 {
+		t.Fatalf("expected rotated files, got %v", files)
+	}
+	if err := compressLogFile(files[0], files[0]+compressSuffix); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := VerifyAuditDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Fatalf("unexpected issues: %v", report.Issues)
+	}
+	if report.Records != 20 || report.FirstSeq != 1 || report.LastSeq != 20 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestVerifyAudit_Tampering(t *testing.T) {
+	cases := []struct {
+		name    string
+		tamper  func(lines []string) []string
+		problem string
+		issues  int
+	}{
+		{"edit", func(lines []string) []string {
+			lines[2] = strings.Replace(lines[2], `"txn":2`, `"txn":9000`, 1)
+			return lines
+		}, "edited", 1},
+		{"remove", func(lines []string) []string {
+			return append(lines[:2], lines[3:]...)
+		}, "gap", 1},
+		{"reorder", func(lines []string) []string {
+			lines[2], lines[3] = lines[3], lines[2]
+			return lines
+		}, "gap", 3},
+	}
+
+	for _, c := range cases {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "audit.log")
+		a := NewAuditLog(ConfigFile{Filename: name})
+		lg := zerolog.New(a).With().Timestamp().Logger()
+		for i := 0; i < 5; i++ {
+			lg.Info().Int("txn", i).Msg("transfer")
+		}
+		a.Close()
+
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := c.tamper(strings.Split(string(bytes.TrimSpace(b)), "\n"))
+		if err := os.WriteFile(name, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := VerifyAudit(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.OK() {
+			t.Errorf("%s: tampering not detected", c.name)
+			continue
+		}
+		if !strings.HasPrefix(report.Issues[0].Problem, c.problem) {
+			t.Errorf("%s: first issue = %q, want %s", c.name, report.Issues[0].Problem, c.problem)
+		}
+		if len(report.Issues) != c.issues {
+			t.Errorf("%s: issues = %v, want %d", c.name, report.Issues, c.issues)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package clog
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// benchLine is a typical request log line of UnaryServerInterceptorWithLogger.
+var benchLine = []byte(`{"level":"info","service":"pkg.Wallet","method":"Transfer","traceID":"9f86d081",` +
+	`"time":1704186000,"dur":0.25,"ip":"10.0.0.5","req":{"from":"acc-1","to":"acc-2","amount":"1250.50",` +
+	`"meta":{"channel":"web","tags":["a","b","c"]}},"md":{"user-agent":"grpc-go/1.50.0",` +
+	`"x-request-id":"2b7e1516-28ae-d2a6-abf7-158809cf4f3c"},"caller":"clog/grpc.go:120","time":1704186000}` + "\n")
+
+func BenchmarkEventTime_Decode(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := decodeEventTime(benchLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEventTime_Scan(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := eventTime(benchLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLogger_Write(b *testing.B) {
+	l := NewLogFile(ConfigFile{Filename: filepath.Join(b.TempDir(), "bench.log")})
+	defer l.Close()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchLine)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := l.Write(benchLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -6,8 +6,7 @@ import (
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog"
-	"os"
-	"strings"
+	"io"
 	"time"
 )
 
@@ -15,73 +14,94 @@ const (
 	FormatPretty      = ""
 	FormatJson        = "json"
 	FormatJsonAndFile = "json_file"
+	FormatLogfmt      = "logfmt"
 	CLoggerKey        = "clogger2"
+	CTraceIDKey       = "ctraceid"
 )
 
 var (
-	std *zerolog.Logger
+	// StatusField key of the HTTP response status.
+	StatusField = "status"
+	// PathField key of the HTTP request path.
+	PathField = "path"
 )
 
+// FileSink is a file sink of NewWithOptions, receiving the events with a
+// level between MinLevel and MaxLevel.
+type FileSink struct {
+	ConfigFile
+
+	// MinLevel and MaxLevel bound the levels written, e.g. "error". An empty
+	// bound is open.
+	MinLevel string
+	MaxLevel string
+
+	// Failover writes to a fallback while the file cannot be written when
+	// set.
+	Failover *FailoverConfig
+
+	// Schema maps the events written to the file to ECS or OTel when set.
+	Schema Schema
+}
+
+// Options configures the package logger.
+type Options struct {
+	Format string
+	Debug  bool
+
+	// Schema maps the events of the JSON formats on stdout to ECS or OTel
+	// when set. Sinks are mapped by wrapping them in a SchemaWriter.
+	Schema Schema
+
+	// Console configures the output of the pretty format.
+	Console ConsoleConfig
+
+	// Files are written next to the output of Format, each with its own
+	// level range and retention. The json_file format defaults to a single
+	// file of every level when Files is empty.
+	//
+	//	Files: []clog.FileSink{
+	//		{ConfigFile: clog.ConfigFile{Filename: "./logs/error.log", MaxAge: 90}, MinLevel: "error"},
+	//		{ConfigFile: clog.ConfigFile{Filename: "./logs/app.log", MaxAge: 5}},
+	//	}
+	Files []FileSink
+
+	// Sinks are written next to the output of Format and the Files, e.g. an
+	// HTTPSink or a GELFWriter. They are shut down with the Logger.
+	Sinks []io.Writer
+
+	// Process adds the fields of the process to every event when set, e.g.
+	// from DetectProcessInfo.
+	Process *ProcessInfo
+
+	// Sequence adds a per-process sequence number to every event, keeping
+	// their order when timestamps collide.
+	Sequence bool
+
+	// Dedup suppresses bursts of similar events across every output when
+	// set.
+	Dedup *DedupConfig
+}
+
 func New(format string, debug bool) {
-	zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	if debug {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	if err := NewWithOptions(Options{Format: format, Debug: debug}); err != nil {
+		panic(err)
 	}
+}
 
-	switch strings.ToLower(format) {
-	case FormatJson:
-		zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-		lg := zerolog.New(os.Stdout).With().Timestamp().Caller().Logger()
-		std = &lg
-
-	case FormatJsonAndFile:
-		zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-		fileLog := ConfigFile{
-			EnableTimeKey: true,
-			TimeKey:       "200601021504",
-			Path:          "./logs",
-			MaxSize:       "1kb",
-			MaxBackups:    0,
-			MaxAge:        5,     //days
-			Compress:      false, // disabled by default
-		}
-
-		multi := zerolog.MultiLevelWriter(os.Stdout, NewLogFile(fileLog))
-
-		lg := zerolog.New(multi).With().Timestamp().Caller().Logger()
-		std = &lg
-
-	default: // pretty format
-		output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
-		//output.FormatLevel = func(i interface{}) string {
-		//	return strings.ToUpper(fmt.Sprintf("| %-6s|", i))
-		//}
-		//output.FormatMessage = func(i interface{}) string {
-		//	return fmt.Sprintf("***%s****", i)
-		//}
-		//output.FormatFieldName = func(i interface{}) string {
-		//	return fmt.Sprintf("%s:", i)
-		//}
-		//output.FormatFieldValue = func(i interface{}) string {
-		//	return strings.ToUpper(fmt.Sprintf("%s", i))
-		//}
-
-		//output := diode.NewWriter(os.Stdout, 1000, 10*time.Millisecond, func(missed int) {
-		//	fmt.Printf("Logger Dropped %d messages", missed)
-		//})
-
-		multi := zerolog.MultiLevelWriter(output)
-
-		lg := zerolog.New(multi).With().Timestamp().Caller().Logger()
-		std = &lg
+// NewWithOptions sets up the default Logger like New, adding the file sinks
+// of opt.
+func NewWithOptions(opt Options) error {
+	l, err := NewLogger(opt)
+	if err != nil {
+		return err
 	}
+	SetDefault(l)
+	return nil
 }
 
 func GetLog() *zerolog.Logger {
-	newLog := new(zerolog.Logger)
-	lg := std.With().Logger()
-	*newLog = lg
-	return newLog
+	return Default().GetLog()
 }
 
 func GetContextLog(ctx context.Context) *zerolog.Logger {
@@ -89,16 +109,58 @@ func GetContextLog(ctx context.Context) *zerolog.Logger {
 	return ctxLog
 }
 
+// GetTraceID returns the trace ID of the request handled in ctx, or "".
+func GetTraceID(ctx context.Context) string {
+	traceID, _ := ctx.Value(CTraceIDKey).(string)
+	return traceID
+}
+
 func WithField(field map[string]interface{}) *zerolog.Logger {
-	newLog := new(zerolog.Logger)
-	lg := std.With().Fields(field).Logger()
-	*newLog = lg
-	return newLog
+	return Default().WithField(field)
 }
 
+// TraceLoggingMiddleware is the TraceLoggingMiddleware of the default Logger
+// at the time of each request.
 func TraceLoggingMiddleware() fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
-		return nil
+		return Default().TraceLoggingMiddleware()(ctx)
+	}
+}
+
+// TraceLoggingMiddleware logs every HTTP request under the trace ID of its
+// TraceIDHeader, or a new one. The request logger and the trace ID are stored
+// in the user context of the request for GetContextLog and GetTraceID.
+func (l *Logger) TraceLoggingMiddleware() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		now := time.Now()
+		traceID := ctx.Get(TraceIDHeader)
+		if traceID == "" {
+			traceID = generator()
+		}
+		log := l.WithField(map[string]interface{}{
+			MethodField:  ctx.Method(),
+			PathField:    ctx.Path(),
+			TraceIDField: traceID,
+		})
+		reqLog, tail := l.tailLogger(log)
+		userCtx := context.WithValue(ctx.UserContext(), CLoggerKey, reqLog)
+		ctx.SetUserContext(context.WithValue(userCtx, CTraceIDKey, traceID))
+
+		err := ctx.Next()
+		status := ctx.Response().StatusCode()
+		if fe, ok := err.(*fiber.Error); ok {
+			status = fe.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+		tail.finish(log, status >= fiber.StatusInternalServerError, time.Since(now))
+
+		e := log.Info()
+		if status >= fiber.StatusInternalServerError {
+			e = log.Error().Err(err)
+		}
+		e.Int(StatusField, status).Dur(DurationField, time.Since(now)).Str(IPField, ctx.IP()).Send()
+		return err
 	}
 }
 
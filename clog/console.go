@@ -0,0 +1,205 @@
+package clog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ensure we always implement zerolog.LevelWriter
+var _ zerolog.LevelWriter = (*ConsoleWriter)(nil)
+
+// ColorMode selects the colours of a ConsoleWriter.
+type ColorMode int
+
+const (
+	// ColorAuto colours the output of terminals, unless NO_COLOR is set or
+	// TERM is dumb.
+	ColorAuto ColorMode = iota
+	// ColorAlways colours the output.
+	ColorAlways
+	// ColorNever writes plain text.
+	ColorNever
+)
+
+const (
+	colorRed     = 31
+	colorGreen   = 32
+	colorYellow  = 33
+	colorMagenta = 35
+	colorCyan    = 36
+	colorBold    = 1
+	colorDim     = 2
+)
+
+// ConsoleConfig configures a ConsoleWriter, the output of the pretty format.
+type ConsoleConfig struct {
+	Color ColorMode
+
+	// TimeFormat of the event time, default time.RFC3339.
+	TimeFormat string
+
+	// FieldOrder lists the fields written first, in order. The other fields
+	// follow sorted.
+	FieldOrder []string
+
+	// PrettyBodies writes the JSON bodies of ReqField and RespField indented
+	// under the line.
+	PrettyBodies bool
+
+	// HighlightTraceID writes the TraceIDField first and in bold.
+	HighlightTraceID bool
+}
+
+// ConsoleWriter writes events as human-readable lines.
+//
+//	15:04:05 INF main.go:12 > user created traceID=0a1b2c3d id=42
+type ConsoleWriter struct {
+	out     io.Writer
+	cf      ConsoleConfig
+	noColor bool
+	// cwd shortens the caller paths, empty when unknown
+	cwd string
+}
+
+func NewConsoleWriter(out io.Writer, cf ConsoleConfig) *ConsoleWriter {
+	if cf.TimeFormat == "" {
+		cf.TimeFormat = time.RFC3339
+	}
+	noColor := cf.Color == ColorNever
+	if cf.Color == ColorAuto {
+		noColor = !colorTerminal(out)
+	}
+	cwd, _ := os.Getwd()
+	return &ConsoleWriter{out: out, cf: cf, noColor: noColor, cwd: cwd}
+}
+
+// colorTerminal reports whether w is a terminal that takes colours.
+func colorTerminal(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Write implements io.Writer.
+func (c *ConsoleWriter) Write(p []byte) (n int, err error) {
+	evt, err := decodeEvent(p)
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	if _, ok := evt[zerolog.TimestampFieldName]; ok {
+		buf.WriteString(c.colorize(sinkEventTime(p).Format(c.cf.TimeFormat), colorDim))
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(c.level(fieldString(evt[zerolog.LevelFieldName])))
+	if caller := fieldString(evt[zerolog.CallerFieldName]); caller != "" {
+		if c.cwd != "" {
+			if rel, err := filepath.Rel(c.cwd, caller); err == nil {
+				caller = rel
+			}
+		}
+		buf.WriteString(" " + c.colorize(caller, colorBold) + c.colorize(" >", colorCyan))
+	}
+	if msg := fieldString(evt[zerolog.MessageFieldName]); msg != "" {
+		buf.WriteString(" " + msg)
+	}
+
+	first := []string{zerolog.ErrorFieldName}
+	if c.cf.HighlightTraceID {
+		first = append(first, TraceIDField)
+	}
+	first = append(first, c.cf.FieldOrder...)
+	var bodies []string
+	for _, k := range eventKeys(evt, first) {
+		switch k {
+		case zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName, zerolog.CallerFieldName:
+			continue
+		}
+		v := evt[k]
+		if c.cf.PrettyBodies && (k == ReqField || k == RespField) {
+			switch v.(type) {
+			case map[string]interface{}, []interface{}:
+				bodies = append(bodies, k)
+				continue
+			}
+		}
+
+		value := logfmtValue(v)
+		switch {
+		case k == zerolog.ErrorFieldName:
+			value = c.colorize(value, colorRed)
+		case k == TraceIDField && c.cf.HighlightTraceID:
+			value = c.colorize(c.colorize(value, colorMagenta), colorBold)
+		}
+		buf.WriteString(" " + c.colorize(k+"=", colorCyan) + value)
+	}
+	buf.WriteByte('\n')
+
+	for _, k := range bodies {
+		b, err := json.MarshalIndent(evt[k], "  ", "  ")
+		if err != nil {
+			continue
+		}
+		buf.WriteString("  " + c.colorize(k+":", colorCyan) + " ")
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	if _, err := c.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteLevel implements zerolog.LevelWriter.
+func (c *ConsoleWriter) WriteLevel(_ zerolog.Level, p []byte) (n int, err error) {
+	return c.Write(p)
+}
+
+// level abbreviates and colours the level of an event.
+func (c *ConsoleWriter) level(level string) string {
+	switch level {
+	case zerolog.LevelTraceValue:
+		return c.colorize("TRC", colorMagenta)
+	case zerolog.LevelDebugValue:
+		return c.colorize("DBG", colorYellow)
+	case zerolog.LevelInfoValue:
+		return c.colorize("INF", colorGreen)
+	case zerolog.LevelWarnValue:
+		return c.colorize("WRN", colorRed)
+	case zerolog.LevelErrorValue:
+		return c.colorize(c.colorize("ERR", colorRed), colorBold)
+	case zerolog.LevelFatalValue:
+		return c.colorize(c.colorize("FTL", colorRed), colorBold)
+	case zerolog.LevelPanicValue:
+		return c.colorize(c.colorize("PNC", colorRed), colorBold)
+	case "":
+		return c.colorize("???", colorBold)
+	default:
+		return strings.ToUpper(fmt.Sprintf("%.3s", level))
+	}
+}
+
+// colorize wraps s in the ANSI code of color, unless colours are off.
+func (c *ConsoleWriter) colorize(s string, color int) string {
+	if c.noColor {
+		return s
+	}
+	return "\x1b[" + strconv.Itoa(color) + "m" + s + "\x1b[0m"
+}
@@ -0,0 +1,34 @@
+package clog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestConsoleWriter(t *testing.T) {
+	var buf bytes.Buffer
+	lg := zerolog.New(NewConsoleWriter(&buf, ConsoleConfig{
+		FieldOrder:       []string{"zone"},
+		PrettyBodies:     true,
+		HighlightTraceID: true,
+	}))
+	lg.Info().Str("alpha", "1").Str("zone", "eu").Str(TraceIDField, "0a1b2c3d").
+		RawJSON(ReqField, []byte(`{"id":42}`)).Msg("created")
+
+	want := "INF created traceID=0a1b2c3d zone=eu alpha=1\n" +
+		"  req: {\n    \"id\": 42\n  }\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	// colours are off for buffers unless forced
+	buf.Reset()
+	lg = zerolog.New(NewConsoleWriter(&buf, ConsoleConfig{Color: ColorAlways, HighlightTraceID: true}))
+	lg.Error().Str(TraceIDField, "0a1b2c3d").Msg("failed")
+	if got := buf.String(); !strings.Contains(got, "\x1b[1m\x1b[35m0a1b2c3d") {
+		t.Errorf("trace ID not highlighted in %q", got)
+	}
+}
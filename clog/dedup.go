@@ -0,0 +1,241 @@
+package clog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/leekchan/accounting"
+	"github.com/rs/zerolog"
+)
+
+// ensure we always implement zerolog.LevelWriter
+var _ zerolog.LevelWriter = (*DedupWriter)(nil)
+
+var (
+	// SuppressedField key of the number of events a summary stands for.
+	SuppressedField = "suppressed"
+	// SampleField key of the message of the suppressed events.
+	SampleField = "sample"
+)
+
+// DedupConfig configures a DedupWriter.
+type DedupConfig struct {
+	// Window of the burst allowance. Summaries of the events suppressed in a
+	// window are written when it ends. The default is 10 seconds.
+	Window time.Duration
+
+	// Burst is the number of similar events let through per window. The
+	// default is 10.
+	Burst int
+
+	// LevelBurst overrides Burst per level. A negative burst lets every
+	// event of the level through, e.g. {zerolog.InfoLevel: -1} only
+	// deduplicates the levels missing from the map.
+	LevelBurst map[zerolog.Level]int
+
+	// Key returns the key of similar events. The default is the level, the
+	// message and the caller of the event. Events with an empty key are
+	// always let through.
+	Key func(level zerolog.Level, p []byte) string
+}
+
+// DedupWriter suppresses bursts of similar events, such as the same error
+// logged thousands of times a second while a dependency is down. The first
+// events of a window are let through, the others are counted and summarized
+// as "suppressed 4,231 similar events" when the window ends.
+//
+// Wrap the writer of a single logger to deduplicate only its events:
+//
+//	dedup := clog.NewDedupWriter(os.Stdout, clog.DedupConfig{Burst: 5})
+//	defer dedup.Close()
+//	lg := clog.GetLog().Output(dedup)
+type DedupWriter struct {
+	w          zerolog.LevelWriter
+	window     time.Duration
+	burst      int
+	levelBurst map[zerolog.Level]int
+	key        func(zerolog.Level, []byte) string
+	// stamp times the summaries like the events of the owning Logger
+	stamp timestampHook
+
+	mu     sync.Mutex
+	events map[string]*dedupEvent
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// dedupEvent counts the similar events of a key in the current window.
+type dedupEvent struct {
+	level      zerolog.Level
+	message    string
+	caller     string
+	count      int
+	suppressed int
+}
+
+func NewDedupWriter(w io.Writer, cf DedupConfig) *DedupWriter {
+	lw, ok := w.(zerolog.LevelWriter)
+	if !ok {
+		lw = zerolog.MultiLevelWriter(w)
+	}
+	if cf.Window <= 0 {
+		cf.Window = 10 * time.Second
+	}
+	if cf.Burst <= 0 {
+		cf.Burst = 10
+	}
+	if cf.Key == nil {
+		cf.Key = dedupKey
+	}
+
+	d := &DedupWriter{
+		w:          lw,
+		window:     cf.Window,
+		burst:      cf.Burst,
+		levelBurst: cf.LevelBurst,
+		key:        cf.Key,
+		events:     map[string]*dedupEvent{},
+		done:       make(chan struct{}),
+	}
+	d.wg.Add(1)
+	go d.run()
+	return d
+}
+
+// Write implements io.Writer, taking the level from the event.
+func (d *DedupWriter) Write(p []byte) (n int, err error) {
+	return d.WriteLevel(eventLevel(p), p)
+}
+
+// WriteLevel implements zerolog.LevelWriter.
+func (d *DedupWriter) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
+	burst := d.burst
+	if b, ok := d.levelBurst[level]; ok {
+		burst = b
+	}
+	if burst < 0 {
+		return d.w.WriteLevel(level, p)
+	}
+	key := d.key(level, p)
+	if key == "" {
+		return d.w.WriteLevel(level, p)
+	}
+
+	d.mu.Lock()
+	evt, ok := d.events[key]
+	if !ok {
+		evt = &dedupEvent{level: level}
+		evt.message, evt.caller = eventMessage(p)
+		d.events[key] = evt
+	}
+	evt.count++
+	pass := evt.count <= burst
+	if !pass {
+		evt.suppressed++
+	}
+	d.mu.Unlock()
+
+	if !pass {
+		return len(p), nil
+	}
+	return d.w.WriteLevel(level, p)
+}
+
+// Close stops the window timer and writes the summaries of the current
+// window. It closes the underlying writer when it is a closer.
+func (d *DedupWriter) Close() error {
+	d.stop()
+	if c, ok := d.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Shutdown is Close, shutting the underlying writer down within ctx.
+func (d *DedupWriter) Shutdown(ctx context.Context) error {
+	d.stop()
+	return shutdownSink(ctx, d.w)
+}
+
+func (d *DedupWriter) stop() {
+	d.closeOnce.Do(func() {
+		close(d.done)
+		d.wg.Wait()
+		d.summarize()
+	})
+}
+
+func (d *DedupWriter) run() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.summarize()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// summarize ends the current window, writing a summary for every key with
+// suppressed events.
+func (d *DedupWriter) summarize() {
+	d.mu.Lock()
+	events := d.events
+	d.events = map[string]*dedupEvent{}
+	d.mu.Unlock()
+
+	lg := zerolog.New(d.w).Hook(d.stamp)
+	for _, evt := range events {
+		if evt.suppressed == 0 {
+			continue
+		}
+		e := lg.WithLevel(evt.level).Int(SuppressedField, evt.suppressed).Str(SampleField, evt.message)
+		if evt.caller != "" {
+			e = e.Str(zerolog.CallerFieldName, evt.caller)
+		}
+		e.Msg(fmt.Sprintf("suppressed %s similar events", accounting.FormatNumberInt(evt.suppressed, 0, ",", ".")))
+	}
+}
+
+// dedupKey keys events on their level, message and caller.
+func dedupKey(level zerolog.Level, p []byte) string {
+	message, caller := eventMessage(p)
+	return level.String() + "\x00" + message + "\x00" + caller
+}
+
+// eventMessage returns the message and the caller of the event p.
+func eventMessage(p []byte) (message, caller string) {
+	fields := [2]string{zerolog.MessageFieldName, zerolog.CallerFieldName}
+	var values [2]string
+	for i, key := range fields {
+		raw, found, ok := scanField(p, key)
+		if !ok {
+			evt, err := decodeEvent(p)
+			if err != nil {
+				return "", ""
+			}
+			return fieldString(evt[zerolog.MessageFieldName]), fieldString(evt[zerolog.CallerFieldName])
+		}
+		if !found {
+			continue
+		}
+		if s, ok := unquoteField(raw); ok {
+			values[i] = s
+		} else {
+			var s string
+			if json.Unmarshal(raw, &s) == nil {
+				values[i] = s
+			}
+		}
+	}
+	return values[0], values[1]
+}
@@ -0,0 +1,114 @@
+package clog
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestDedupWriter(t *testing.T) {
+	var buf bytes.Buffer
+	d := NewDedupWriter(&buf, DedupConfig{
+		Window:     time.Hour,
+		Burst:      2,
+		LevelBurst: map[zerolog.Level]int{zerolog.InfoLevel: -1},
+	})
+	lg := zerolog.New(d).With().Timestamp().Logger()
+
+	for i := 0; i < 4233; i++ {
+		lg.Error().Str("i", "varies").Msg("downstream unavailable")
+	}
+	for i := 0; i < 5; i++ {
+		lg.Info().Msg("kept")
+	}
+	lg.Error().Msg("other error")
+	d.summarize()
+	lg.Error().Msg("downstream unavailable")
+	d.Close()
+
+	lines := decodeLines(t, &buf)
+	count := map[interface{}]int{}
+	for _, m := range lines {
+		count[m["message"]]++
+	}
+	want := map[interface{}]int{
+		"downstream unavailable":          3,
+		"kept":                            5,
+		"other error":                     1,
+		"suppressed 4,231 similar events": 1,
+	}
+	if len(count) != len(want) {
+		t.Errorf("messages = %v, want %v", count, want)
+	}
+	for msg, n := range want {
+		if count[msg] != n {
+			t.Errorf("%q logged %d times, want %d", msg, count[msg], n)
+		}
+	}
+	for _, m := range lines {
+		if m["message"] == "suppressed 4,231 similar events" {
+			if m["level"] != "error" || m[SuppressedField] != 4231.0 || m[SampleField] != "downstream unavailable" {
+				t.Errorf("summary = %v", m)
+			}
+		}
+	}
+}
+
+func TestDedupWriter_Key(t *testing.T) {
+	var buf bytes.Buffer
+	d := NewDedupWriter(&buf, DedupConfig{
+		Window: time.Hour,
+		Burst:  1,
+		Key: func(level zerolog.Level, p []byte) string {
+			raw, _, _ := scanField(p, "code")
+			return string(raw)
+		},
+	})
+	d.Write([]byte(`{"level":"error","code":"A","message":"a1"}` + "\n"))
+	d.Write([]byte(`{"level":"error","code":"A","message":"a2"}` + "\n"))
+	d.Write([]byte(`{"level":"error","code":"B","message":"b1"}` + "\n"))
+	d.Write([]byte(`{"level":"error","message":"no key"}` + "\n"))
+	d.Write([]byte(`{"level":"error","message":"no key"}` + "\n"))
+	d.Close()
+
+	var msgs []interface{}
+	for _, m := range decodeLines(t, &buf) {
+		msgs = append(msgs, m["message"])
+	}
+	want := []interface{}{"a1", "b1", "no key", "no key", "suppressed 1 similar events"}
+	if len(msgs) != len(want) {
+		t.Fatalf("got %v, want %v", msgs, want)
+	}
+	for i := range want {
+		if msgs[i] != want[i] {
+			t.Errorf("line %d = %v, want %v", i, msgs[i], want[i])
+		}
+	}
+}
+
+func TestNewLogger_DedupSummaryTime(t *testing.T) {
+	var buf bytes.Buffer
+	lg, err := NewLogger(Options{Format: FormatJson, Sinks: []io.Writer{&buf}, Dedup: &DedupConfig{Burst: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		lg.GetLog().Error().Msg("boom")
+	}
+	if err := lg.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 || lines[1][SuppressedField] != 2.0 {
+		t.Fatalf("lines = %v, want the event and its summary", lines)
+	}
+	for _, m := range lines {
+		if _, ok := m[zerolog.TimestampFieldName].(float64); !ok {
+			t.Errorf("time = %#v in %v, want a Unix timestamp on every line", m[zerolog.TimestampFieldName], m)
+		}
+	}
+}
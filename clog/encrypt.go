@@ -0,0 +1,371 @@
+package clog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// Encrypted log files start with encryptMagic and hold a sequence of records:
+//
+//	type (1 byte) | payload length (uint32) | payload
+//
+// A key record ('K') carries the key ID and a random nonce prefix for the data
+// records that follow it. A data record ('D') is one AES-GCM sealed chunk, its
+// nonce being the prefix and a counter of the data records since the last key
+// record. Every chunk authenticates on its own, so the complete records of a
+// partially written file can still be read.
+const (
+	encryptMagic      = "CLOGENC1"
+	encryptRecordKey  = 'K'
+	encryptRecordData = 'D'
+	encryptMaxRecord  = 16 * 1024 * 1024
+)
+
+// KeyProvider supplies the AES keys of encrypted log files. Keys must be 16, 24
+// or 32 bytes long to select AES-128, AES-192 or AES-256.
+type KeyProvider interface {
+	// CurrentKey returns the key new files are encrypted with.
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key with the given ID, for reading files back.
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider holding its keys in memory.
+type StaticKeys struct {
+	// Current is the ID of the key new files are encrypted with.
+	Current string
+	Keys    map[string][]byte
+}
+
+func (s StaticKeys) CurrentKey() (string, []byte, error) {
+	key, err := s.Key(s.Current)
+	return s.Current, key, err
+}
+
+func (s StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := s.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+	return key, nil
+}
+
+// LoadStaticKeys reads a key file of "id=hex key" lines. The last key of the
+// file is the current one. Blank lines and lines starting with # are skipped.
+func LoadStaticKeys(name string) (StaticKeys, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return StaticKeys{}, err
+	}
+	defer f.Close()
+
+	keys := StaticKeys{Keys: map[string][]byte{}}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, hexKey, ok := strings.Cut(line, "=")
+		if !ok {
+			return StaticKeys{}, fmt.Errorf("%s:%d: expected id=key", name, n)
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(hexKey))
+		if err != nil {
+			return StaticKeys{}, fmt.Errorf("%s:%d: %s", name, n, err)
+		}
+		id = strings.TrimSpace(id)
+		keys.Keys[id] = key
+		keys.Current = id
+	}
+	if err := sc.Err(); err != nil {
+		return StaticKeys{}, err
+	}
+	if len(keys.Keys) == 0 {
+		return StaticKeys{}, fmt.Errorf("%s: no keys", name)
+	}
+	return keys, nil
+}
+
+// encryptWriter seals every write into a data record.
+type encryptWriter struct {
+	w       io.Writer
+	keys    KeyProvider
+	aead    cipher.AEAD
+	prefix  [8]byte
+	counter uint32
+	buf     []byte
+	// size is the number of bytes written to w
+	size int64
+}
+
+// newEncryptWriter starts a key record on w, preceded by the file magic if w
+// is a new file.
+func newEncryptWriter(w io.Writer, keys KeyProvider, newFile bool) (*encryptWriter, error) {
+	e := &encryptWriter{w: w, keys: keys}
+	if newFile {
+		n, err := w.Write([]byte(encryptMagic))
+		e.size += int64(n)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := e.startKey(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// startKey writes a key record with the current key and a new nonce prefix.
+func (e *encryptWriter) startKey() error {
+	id, key, err := e.keys.CurrentKey()
+	if err != nil {
+		return fmt.Errorf("cannot get encryption key: %s", err)
+	}
+	if len(id) > math.MaxUint16 {
+		return errors.New("encryption key id too long")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	if _, err := rand.Read(e.prefix[:]); err != nil {
+		return fmt.Errorf("cannot generate nonce: %s", err)
+	}
+
+	payload := make([]byte, 0, 2+len(id)+len(e.prefix))
+	payload = binary.BigEndian.AppendUint16(payload, uint16(len(id)))
+	payload = append(payload, id...)
+	payload = append(payload, e.prefix[:]...)
+	if err := e.writeRecord(encryptRecordKey, payload); err != nil {
+		return err
+	}
+	e.aead = aead
+	e.counter = 0
+	return nil
+}
+
+// Write implements io.Writer, writing p as one data record.
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.counter == math.MaxUint32 {
+		if err := e.startKey(); err != nil {
+			return 0, err
+		}
+	}
+
+	nonce := make([]byte, 0, 12)
+	nonce = append(nonce, e.prefix[:]...)
+	nonce = binary.BigEndian.AppendUint32(nonce, e.counter)
+	e.buf = e.aead.Seal(e.buf[:0], nonce, p, []byte{encryptRecordData})
+	if err := e.writeRecord(encryptRecordData, e.buf); err != nil {
+		return 0, err
+	}
+	e.counter++
+	return len(p), nil
+}
+
+// writeRecord writes the record with a single Write call, so a failed write
+// leaves at most one partial record behind.
+func (e *encryptWriter) writeRecord(typ byte, payload []byte) error {
+	rec := make([]byte, 0, 5+len(payload))
+	rec = append(rec, typ)
+	rec = binary.BigEndian.AppendUint32(rec, uint32(len(payload)))
+	rec = append(rec, payload...)
+	n, err := e.w.Write(rec)
+	e.size += int64(n)
+	return err
+}
+
+// sealedSize returns the size of the data record of n bytes of plaintext.
+func (e *encryptWriter) sealedSize(n int) int64 {
+	return int64(5 + n + e.aead.Overhead())
+}
+
+// truncateEncrypted cuts the partial record a crash or a short write left at
+// the end of the encrypted file name, so records appended to it stay readable.
+// It returns the size of the file.
+func truncateEncrypted(name string) (int64, error) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	end, err := encryptedEnd(f)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", name, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if end < info.Size() {
+		if err := f.Truncate(end); err != nil {
+			return 0, err
+		}
+	}
+	return end, nil
+}
+
+// encryptedEnd returns the offset just past the last complete record of r, or
+// 0 when r does not hold the whole magic.
+func encryptedEnd(r io.Reader) (int64, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(encryptMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if string(magic) != encryptMagic {
+		return 0, errors.New("not an encrypted log file")
+	}
+
+	end := int64(len(encryptMagic))
+	var hdr [5]byte
+	for {
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return end, nil
+			}
+			return end, err
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[1:]))
+		if n, err := io.CopyN(io.Discard, br, size); n < size {
+			if err == io.EOF {
+				return end, nil
+			}
+			return end, err
+		}
+		end += int64(len(hdr)) + size
+	}
+}
+
+// decryptReader reads the plaintext of an encrypted log stream.
+type decryptReader struct {
+	r       *bufio.Reader
+	keys    KeyProvider
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	plain   []byte
+	err     error
+}
+
+// NewDecryptReader returns a reader of the plaintext of an encrypted log file.
+// A record cut short at the end of r, as left by a crash, ends the stream
+// without an error.
+func NewDecryptReader(r io.Reader, keys KeyProvider) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(encryptMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != encryptMagic {
+		return nil, errors.New("not an encrypted log file")
+	}
+	if keys == nil {
+		return nil, errors.New("encrypted log file needs a key provider")
+	}
+	return &decryptReader{r: br, keys: keys}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// next reads one record.
+func (d *decryptReader) next() error {
+	var hdr [5]byte
+	if _, err := io.ReadFull(d.r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return io.EOF
+		}
+		return err
+	}
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if size > encryptMaxRecord {
+		return fmt.Errorf("encrypted record too large: %d bytes", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(d.r, payload); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return io.EOF
+		}
+		return err
+	}
+
+	switch hdr[0] {
+	case encryptRecordKey:
+		if len(payload) < 2 {
+			return errors.New("malformed key record")
+		}
+		idLen := int(binary.BigEndian.Uint16(payload))
+		if len(payload) != 2+idLen+8 {
+			return errors.New("malformed key record")
+		}
+		key, err := d.keys.Key(string(payload[2 : 2+idLen]))
+		if err != nil {
+			return err
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return err
+		}
+		d.aead = aead
+		d.prefix = payload[2+idLen:]
+		d.counter = 0
+		return nil
+
+	case encryptRecordData:
+		if d.aead == nil {
+			return errors.New("data record before key record")
+		}
+		nonce := make([]byte, 0, 12)
+		nonce = append(nonce, d.prefix...)
+		nonce = binary.BigEndian.AppendUint32(nonce, d.counter)
+		plain, err := d.aead.Open(payload[:0], nonce, payload, []byte{encryptRecordData})
+		if err != nil {
+			return fmt.Errorf("cannot decrypt record: %s", err)
+		}
+		d.plain = plain
+		d.counter++
+		return nil
+
+	default:
+		return fmt.Errorf("unknown record type %q", hdr[0])
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %s", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// isEncrypted reports whether the file starts with the encryption magic.
+func isEncrypted(f io.ReaderAt) bool {
+	magic := make([]byte, len(encryptMagic))
+	n, _ := f.ReadAt(magic, 0)
+	return bytes.Equal(magic[:n], []byte(encryptMagic))
+}
@@ -0,0 +1,152 @@
+package clog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func testKeys() StaticKeys {
+	return StaticKeys{
+		Current: "k2",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+			"k2": bytes.Repeat([]byte{2}, 32),
+		},
+	}
+}
+
+func readLogFile(t *testing.T, name string, keys KeyProvider) string {
+	t.Helper()
+	rc, err := OpenLogFile(name, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestEncryptedLogFile(t *testing.T) {
+	fakeClock(t)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	l := NewLogFile(ConfigFile{Filename: name, MaxSize: "400", Compress: true, KeyProvider: testKeys()})
+	lg := zerolog.New(l).With().Timestamp().Logger()
+	for i := 0; i < 10; i++ {
+		lg.Info().Int("customer", i).Msg("secret")
+	}
+	// waits for the mill to compress the backups
+	if err := l.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all string
+	var compressed int
+	for _, e := range entries {
+		fn := filepath.Join(dir, e.Name())
+		raw, _ := os.ReadFile(fn)
+		if !bytes.HasPrefix(raw, []byte(encryptMagic)) {
+			t.Errorf("%s is not encrypted", e.Name())
+		}
+		if bytes.Contains(raw, []byte("secret")) {
+			t.Errorf("%s holds plaintext", e.Name())
+		}
+		if strings.HasSuffix(fn, compressSuffix) {
+			compressed++
+		} else if len(raw) > 400 {
+			t.Errorf("%s holds %d bytes, over MaxSize", e.Name(), len(raw))
+		}
+		all += readLogFile(t, fn, testKeys())
+	}
+	if compressed == 0 {
+		t.Error("no backup was compressed")
+	}
+	if got := strings.Count(all, `"message":"secret"`); got != 10 {
+		t.Errorf("read back %d lines, want 10", got)
+	}
+}
+
+func TestEncryptedLogFile_Partial(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	l := NewLogFile(ConfigFile{Filename: name, KeyProvider: testKeys()})
+	lg := zerolog.New(l).With().Timestamp().Logger()
+	lg.Info().Msg("one")
+	lg.Info().Msg("two")
+	l.Close()
+
+	// reopening appends under a new key record
+	keys := testKeys()
+	keys.Current = "k1"
+	l = NewLogFile(ConfigFile{Filename: name, KeyProvider: keys})
+	lg = zerolog.New(l).With().Timestamp().Logger()
+	lg.Info().Msg("three")
+	l.Close()
+
+	raw, _ := os.ReadFile(name)
+	if err := os.WriteFile(name, raw[:len(raw)-5], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readLogFile(t, name, testKeys())
+	if !strings.Contains(got, `"one"`) || !strings.Contains(got, `"two"`) || strings.Contains(got, `"three"`) {
+		t.Errorf("partial read = %q", got)
+	}
+
+	if _, err := OpenLogFile(name, nil); err == nil {
+		t.Error("opening an encrypted file without keys should fail")
+	}
+	wrong := StaticKeys{Current: "k2", Keys: map[string][]byte{"k2": bytes.Repeat([]byte{9}, 32)}}
+	rc, err := OpenLogFile(name, wrong)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := io.ReadAll(rc); err == nil {
+		t.Error("reading with a wrong key should fail")
+	}
+}
+
+func TestEncryptedLogFile_ReopenPartial(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	write := func(msgs ...string) {
+		l := NewLogFile(ConfigFile{Filename: name, KeyProvider: testKeys()})
+		lg := zerolog.New(l).With().Timestamp().Logger()
+		for _, msg := range msgs {
+			lg.Info().Msg(msg)
+		}
+		l.Close()
+	}
+
+	write("one", "two", "three")
+	raw, _ := os.ReadFile(name)
+	if err := os.WriteFile(name, raw[:len(raw)-5], 0600); err != nil {
+		t.Fatal(err)
+	}
+	// the partial record is cut before the new records are appended
+	write("four", "five", "six")
+
+	got := readLogFile(t, name, testKeys())
+	for _, msg := range []string{"one", "two", "four", "five", "six"} {
+		if !strings.Contains(got, `"`+msg+`"`) {
+			t.Errorf("%s missing from %q", msg, got)
+		}
+	}
+	if strings.Contains(got, `"three"`) {
+		t.Errorf("partial record read back: %q", got)
+	}
+}
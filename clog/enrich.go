@@ -0,0 +1,100 @@
+package clog
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+// Enricher returns identity fields (user ID, tenant, operator, ...) for an
+// incoming request. The fields are attached to the per-request logger, so
+// every line logged through the context logger carries them.
+type Enricher func(ctx context.Context, md metadata.MD) map[string]interface{}
+
+var (
+	// Enrichers run by UnaryServerInterceptorWithLogger for every call.
+	Enrichers []Enricher
+)
+
+// EnrichLogger runs Enrichers against ctx and returns a logger carrying the
+// fields they found. The logger is returned unchanged when nothing was found.
+func EnrichLogger(ctx context.Context, logger *zerolog.Logger) *zerolog.Logger {
+	if len(Enrichers) == 0 {
+		return logger
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	fields := map[string]interface{}{}
+	for _, enrich := range Enrichers {
+		for k, v := range enrich(ctx, md) {
+			fields[k] = v
+		}
+	}
+	if len(fields) == 0 {
+		return logger
+	}
+
+	newLog := new(zerolog.Logger)
+	*newLog = logger.With().Fields(fields).Logger()
+	return newLog
+}
+
+// MetadataEnricher logs the value of the metadata key as field.
+func MetadataEnricher(key, field string) Enricher {
+	return func(_ context.Context, md metadata.MD) map[string]interface{} {
+		if v := strings.Join(md.Get(key), ","); v != "" {
+			return map[string]interface{}{field: v}
+		}
+		return nil
+	}
+}
+
+// ContextEnricher logs the context value stored under key as field.
+func ContextEnricher(key interface{}, field string) Enricher {
+	return func(ctx context.Context, _ metadata.MD) map[string]interface{} {
+		if v := ctx.Value(key); v != nil {
+			return map[string]interface{}{field: v}
+		}
+		return nil
+	}
+}
+
+// JWTClaimEnricher logs a claim of the bearer token found in the
+// "authorization" metadata as field, e.g. JWTClaimEnricher("sub", "userID").
+// The token signature is not verified; authentication is expected to have
+// happened elsewhere.
+func JWTClaimEnricher(claim, field string) Enricher {
+	return func(_ context.Context, md metadata.MD) map[string]interface{} {
+		claims := bearerClaims(md)
+		if v, ok := claims[claim]; ok && v != nil {
+			return map[string]interface{}{field: v}
+		}
+		return nil
+	}
+}
+
+// bearerClaims decodes the payload of the bearer token in md.
+func bearerClaims(md metadata.MD) map[string]interface{} {
+	auth := strings.Join(md.Get("authorization"), "")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return nil
+	}
+
+	parts := strings.Split(strings.TrimSpace(auth[7:]), ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil
+	}
+	return claims
+}
@@ -0,0 +1,217 @@
+package clog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ensure we always implement zerolog.LevelWriter
+var _ zerolog.LevelWriter = (*FailoverWriter)(nil)
+
+// FailoverConfig configures a FailoverWriter.
+type FailoverConfig struct {
+	// Fallback receives the events while the sink is failing. The default is
+	// os.Stderr.
+	Fallback io.Writer
+
+	// Backoff is the first wait before the sink is retried after a failure.
+	// It doubles on every failed retry up to MaxBackoff. The defaults are 1
+	// second and 1 minute.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// OnHealth is called when the sink starts failing, with the error, and
+	// when it recovers, e.g. to report degraded logging in a readiness probe.
+	// It runs after the write, outside the lock of the writer, so it may log
+	// through the same logger. Calls of concurrent writes may arrive out of
+	// order; Healthy reports the current state.
+	OnHealth func(healthy bool, err error)
+}
+
+// FailoverStats are the counters of a FailoverWriter.
+type FailoverStats struct {
+	Healthy    bool
+	Errors     uint64
+	Fallbacks  uint64
+	Recoveries uint64
+	LastError  error
+}
+
+// FailoverWriter writes to a sink, falling back to another writer while the
+// sink fails, e.g. when the disk is full. The sink is retried after a
+// backoff instead of on every event.
+//
+//	file := clog.NewFailoverWriter(clog.NewLogFile(cf), clog.FailoverConfig{
+//		OnHealth: func(healthy bool, err error) { loggingHealthy.Store(healthy) },
+//	})
+type FailoverWriter struct {
+	w          zerolog.LevelWriter
+	fallback   zerolog.LevelWriter
+	backoff    time.Duration
+	maxBackoff time.Duration
+	onHealth   func(bool, error)
+	now        func() time.Time
+
+	mu       sync.Mutex
+	stats    FailoverStats
+	retryAt  time.Time
+	nextWait time.Duration
+}
+
+func NewFailoverWriter(w io.Writer, cf FailoverConfig) *FailoverWriter {
+	if cf.Fallback == nil {
+		cf.Fallback = os.Stderr
+	}
+	if cf.Backoff <= 0 {
+		cf.Backoff = time.Second
+	}
+	if cf.MaxBackoff < cf.Backoff {
+		cf.MaxBackoff = time.Minute
+		if cf.MaxBackoff < cf.Backoff {
+			cf.MaxBackoff = cf.Backoff
+		}
+	}
+	return &FailoverWriter{
+		w:          levelWriter(w),
+		fallback:   levelWriter(cf.Fallback),
+		backoff:    cf.Backoff,
+		maxBackoff: cf.MaxBackoff,
+		onHealth:   cf.OnHealth,
+		now:        time.Now,
+		stats:      FailoverStats{Healthy: true},
+		nextWait:   cf.Backoff,
+	}
+}
+
+// Write implements io.Writer, taking the level from the event.
+func (f *FailoverWriter) Write(p []byte) (n int, err error) {
+	return f.WriteLevel(eventLevel(p), p)
+}
+
+// WriteLevel implements zerolog.LevelWriter. It only fails when both the sink
+// and the fallback fail.
+func (f *FailoverWriter) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
+	f.mu.Lock()
+	n, change, err := f.writeLevel(level, p)
+	f.mu.Unlock()
+
+	// OnHealth runs unlocked, so it may log through this sink
+	if change != nil && f.onHealth != nil {
+		f.onHealth(change.healthy, change.err)
+	}
+	return n, err
+}
+
+// healthChange is a change of the health of the sink, reported to OnHealth.
+type healthChange struct {
+	healthy bool
+	err     error
+}
+
+// writeLevel writes p to the sink or the fallback, returning the change of
+// health it caused. f.mu is held.
+func (f *FailoverWriter) writeLevel(level zerolog.Level, p []byte) (int, *healthChange, error) {
+	var change *healthChange
+	if f.stats.Healthy || !f.now().Before(f.retryAt) {
+		n, err := f.w.WriteLevel(level, p)
+		if err == nil {
+			return n, f.recovered(), nil
+		}
+		change = f.failed(err)
+	}
+
+	f.stats.Fallbacks++
+	if _, errFallback := f.fallback.WriteLevel(level, p); errFallback != nil {
+		return 0, change, fmt.Errorf("sink: %s, fallback: %s", f.stats.LastError, errFallback)
+	}
+	return len(p), change, nil
+}
+
+// failed records a write error of the sink and schedules its retry.
+func (f *FailoverWriter) failed(err error) *healthChange {
+	var change *healthChange
+	f.stats.Errors++
+	f.stats.LastError = err
+	if f.stats.Healthy {
+		f.stats.Healthy = false
+		f.nextWait = f.backoff
+		change = &healthChange{healthy: false, err: err}
+	} else if f.nextWait *= 2; f.nextWait > f.maxBackoff {
+		f.nextWait = f.maxBackoff
+	}
+	f.retryAt = f.now().Add(f.nextWait)
+	return change
+}
+
+// recovered records a successful write of the sink.
+func (f *FailoverWriter) recovered() *healthChange {
+	if f.stats.Healthy {
+		return nil
+	}
+	f.stats.Healthy = true
+	f.stats.Recoveries++
+	f.nextWait = f.backoff
+	return &healthChange{healthy: true}
+}
+
+// Healthy reports whether the sink is written to.
+func (f *FailoverWriter) Healthy() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats.Healthy
+}
+
+// Stats returns the counters of f.
+func (f *FailoverWriter) Stats() FailoverStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// Close implements io.Closer, closing the sink when it is a closer.
+func (f *FailoverWriter) Close() error {
+	if c, ok := f.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Shutdown shuts the sink down within ctx.
+func (f *FailoverWriter) Shutdown(ctx context.Context) error {
+	return shutdownSink(ctx, f.w)
+}
+
+// levelWriter returns w as a zerolog.LevelWriter, keeping its Close and
+// Shutdown methods reachable.
+func levelWriter(w io.Writer) zerolog.LevelWriter {
+	if lw, ok := w.(zerolog.LevelWriter); ok {
+		return lw
+	}
+	return plainLevelWriter{w}
+}
+
+// plainLevelWriter ignores the level of the events.
+type plainLevelWriter struct {
+	io.Writer
+}
+
+func (w plainLevelWriter) WriteLevel(_ zerolog.Level, p []byte) (int, error) {
+	return w.Write(p)
+}
+
+func (w plainLevelWriter) Close() error {
+	if c, ok := w.Writer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (w plainLevelWriter) Shutdown(ctx context.Context) error {
+	return shutdownSink(ctx, w.Writer)
+}
@@ -0,0 +1,117 @@
+package clog
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// flakyWriter fails while down is set.
+type flakyWriter struct {
+	bytes.Buffer
+	down  bool
+	calls int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.calls++
+	if w.down {
+		return 0, errors.New("no space left on device")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestFailoverWriter(t *testing.T) {
+	var fallback bytes.Buffer
+	var health []bool
+	primary := &flakyWriter{down: true}
+	f := NewFailoverWriter(primary, FailoverConfig{
+		Fallback:   &fallback,
+		Backoff:    time.Second,
+		MaxBackoff: 2 * time.Second,
+		OnHealth:   func(healthy bool, err error) { health = append(health, healthy) },
+	})
+	now := time.Unix(1704186000, 0)
+	f.now = func() time.Time { return now }
+	lg := zerolog.New(f)
+
+	lg.Info().Msg("1") // fails, retry in 1s
+	lg.Info().Msg("2") // backing off
+	now = now.Add(time.Second)
+	lg.Info().Msg("3") // fails again, retry in 2s
+	now = now.Add(time.Second)
+	lg.Info().Msg("4") // backing off
+	primary.down = false
+	now = now.Add(time.Second)
+	lg.Info().Msg("5") // recovers
+	lg.Info().Msg("6")
+
+	if primary.calls != 4 {
+		t.Errorf("sink written %d times, want 4", primary.calls)
+	}
+	if got := len(decodeLines(t, &fallback)); got != 4 {
+		t.Errorf("fallback got %d lines, want 4", got)
+	}
+	if got := len(decodeLines(t, &primary.Buffer)); got != 2 {
+		t.Errorf("sink got %d lines, want 2", got)
+	}
+	if len(health) != 2 || health[0] || !health[1] {
+		t.Errorf("health callbacks = %v, want [false true]", health)
+	}
+	stats := f.Stats()
+	if !stats.Healthy || stats.Errors != 2 || stats.Fallbacks != 4 || stats.Recoveries != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestLogFile_ReopensAfterWriteError(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	l := NewLogFile(ConfigFile{Filename: name})
+	defer l.Close()
+	lg := zerolog.New(l).With().Timestamp().Logger()
+
+	lg.Info().Msg("first")
+	// the file handle goes bad, as when the disk fails
+	l.file.Close()
+	if _, err := l.Write([]byte(`{"time":1704186000,"message":"lost"}` + "\n")); err == nil {
+		t.Fatal("want a write error")
+	}
+	lg.Info().Msg("after")
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("first")) || !bytes.Contains(b, []byte("after")) {
+		t.Errorf("file = %q, want the writes before and after the error", b)
+	}
+}
+
+func TestFailoverWriter_OnHealthLogs(t *testing.T) {
+	var fallback bytes.Buffer
+	var lg zerolog.Logger
+	f := NewFailoverWriter(&flakyWriter{down: true}, FailoverConfig{
+		Fallback: &fallback,
+		OnHealth: func(healthy bool, err error) { lg.Warn().Err(err).Msg("logging degraded") },
+	})
+	lg = zerolog.New(f)
+
+	done := make(chan struct{})
+	go func() {
+		lg.Info().Msg("hello")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnHealth logging through the writer deadlocked")
+	}
+	if got := fallback.String(); !bytes.Contains([]byte(got), []byte("logging degraded")) {
+		t.Errorf("fallback = %q", got)
+	}
+}
@@ -1,17 +1,17 @@
 package clog
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
-	"github.com/rs/zerolog"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -60,6 +60,24 @@ type ConfigFile struct {
 	LocalTime     bool
 	Compress      bool
 	TImeZone      *time.Location
+
+	// Rotation rotates the file by interval or line count, next to MaxSize.
+	Rotation RotationPolicy
+
+	// FilenameTemplate names the files written in Path, replacing the time
+	// key. The {time} token is formatted with TimeKey, or down to the
+	// Rotation interval when TimeKey is empty.
+	//
+	//	FilenameTemplate: "{service}-{host}-{time}-{seq}"  // billing-web-1-20240102-1_latest.log
+	FilenameTemplate string
+
+	// Service fills the {service} token, default the application of
+	// DetectProcessInfo.
+	Service string
+
+	// KeyProvider enables encryption at rest when set. Files are written as
+	// AES-GCM sealed chunks, see NewDecryptReader and OpenLogFile.
+	KeyProvider KeyProvider
 }
 
 type logger struct {
@@ -94,7 +112,8 @@ type logger struct {
 
 	// MaxBackups is the maximum number of old log files to retain.  The default
 	// is to retain all old log files (though MaxAge may still cause them to get
-	// deleted.)
+	// deleted.) The backups of every time key of the logger count together,
+	// those of other sinks sharing the directory do not.
 	MaxBackups int `json:"maxbackups" yaml:"maxbackups"`
 
 	// LocalTime determines if the time used for formatting the timestamps in
@@ -106,15 +125,42 @@ type logger struct {
 	// using gzip. The default is not to perform compression.
 	Compress bool `json:"compress" yaml:"compress"`
 
+	// KeyProvider encrypts the log files when set. Rotated files are
+	// compressed before they are encrypted.
+	KeyProvider KeyProvider
+
+	// Rotation rotates the file next to MaxSize.
+	Rotation RotationPolicy
+
+	// Template names the time key files, with the Service and Host of its
+	// tokens.
+	Template string
+	Service  string
+	Host     string
+
 	currentTkFileName string
 	currentTk         string
 	lastLogTime       time.Time
 	size              int64
-	file              *os.File
-	mu                sync.Mutex
+	lines             int64
+	// period is the start of the Rotation interval of the file.
+	period time.Time
+	// seq numbers the files of a Template key.
+	seq int
+	// keyRe matches the keys of the files written by the logger, see
+	// parseKey.
+	keyRe     *regexp.Regexp
+	keyTokens []string
+	file      *os.File
+	enc       *encryptWriter
+	mu        sync.Mutex
 
 	millCh    chan bool
+	millDone  chan struct{}
 	startMill sync.Once
+	shutdown  bool
+	// recovered is set once the files of a crashed process are recovered.
+	recovered bool
 }
 
 var (
@@ -134,8 +180,8 @@ var (
 )
 
 func NewLogFile(cf ConfigFile) *logger {
-	var loc *time.Location
-	if cf.TImeZone == nil {
+	loc := cf.TImeZone
+	if loc == nil {
 		bangkokTZ, err := time.LoadLocation("Asia/Bangkok")
 		if err != nil {
 			panic("cannot load location Asia/Bangkok")
@@ -143,14 +189,31 @@ func NewLogFile(cf ConfigFile) *logger {
 		loc = bangkokTZ
 	}
 
+	template := strings.TrimSuffix(cf.FilenameTemplate, logSuffix)
+	timeKey := cf.TimeKey
+	if template != "" {
+		cf.EnableTimeKey = true
+		if timeKey == "" {
+			timeKey = cf.Rotation.timeLayout()
+		}
+	}
 	if cf.EnableTimeKey && strings.TrimSpace(cf.Path) == "" {
 		panic("path is required")
 	}
 
+	var service, host string
+	if strings.Contains(template, TokenService) || strings.Contains(template, TokenHost) {
+		info := DetectProcessInfo()
+		service, host = cf.Service, info.Host
+		if service == "" {
+			service = info.App
+		}
+	}
+
 	maxSize := int(toMBSize(cf.MaxSize))
-	return &logger{
+	l := &logger{
 		EnableTimeKey: cf.EnableTimeKey,
-		TimeKey:       cf.TimeKey,
+		TimeKey:       timeKey,
 		TimeZone:      loc,
 		Path:          cf.Path,
 		Filename:      cf.Filename,
@@ -159,7 +222,14 @@ func NewLogFile(cf ConfigFile) *logger {
 		MaxBackups:    cf.MaxBackups,
 		LocalTime:     cf.LocalTime,
 		Compress:      cf.Compress,
-	}
+		KeyProvider:   cf.KeyProvider,
+		Rotation:      cf.Rotation,
+		Template:      template,
+		Service:       service,
+		Host:          host,
+	}
+	l.keyRe, l.keyTokens = l.keyPattern()
+	return l
 }
 
 // Write implements io.Writer.  If a write would cause the log file to be larger
@@ -177,19 +247,11 @@ func (l *logger) Write(p []byte) (n int, err error) {
 		)
 	}
 
-	var evt map[string]interface{}
 	p = decodeIfBinaryToBytes(p)
-	d := json.NewDecoder(bytes.NewReader(p))
-	d.UseNumber()
-	err = d.Decode(&evt)
+	l.lastLogTime, err = eventTime(p)
 	if err != nil {
-		return n, fmt.Errorf("cannot decode event: %s", err)
-	}
-
-	if _, ok := evt[zerolog.TimestampFieldName]; !ok {
-		panic("field time is missing")
+		return n, err
 	}
-	l.lastLogTime = toTime(evt[zerolog.TimestampFieldName])
 
 	if l.file == nil {
 		if err = l.openExistingOrNew(len(p)); err != nil {
@@ -197,14 +259,27 @@ func (l *logger) Write(p []byte) (n int, err error) {
 		}
 	}
 
-	if l.size+writeLen > l.max() || l.lastLogTime.Format(l.TimeKey) != l.currentTk {
+	if l.rotateDue(p) {
 		if err := l.rotate(); err != nil {
 			return 0, err
 		}
 	}
 
-	n, err = l.file.Write(p)
-	l.size += int64(n)
+	if l.enc != nil {
+		// the file grows by the sealed record, not by the plaintext
+		size := l.enc.size
+		n, err = l.enc.Write(p)
+		l.size += l.enc.size - size
+	} else {
+		n, err = l.file.Write(p)
+		l.size += int64(n)
+	}
+	l.lines += int64(bytes.Count(p[:n], []byte{'\n'}))
+	if err != nil {
+		// reopen on the next write, once the disk has room again or the
+		// directory is back
+		_ = l.close()
+	}
 
 	return n, err
 }
@@ -216,6 +291,36 @@ func (l *logger) Close() error {
 	return l.close()
 }
 
+// Shutdown closes the current logfile and stops the mill goroutine, waiting
+// for a running or pending compression until ctx is done. Files are no
+// longer compressed or removed after Shutdown, but late writes still reopen
+// the logfile.
+func (l *logger) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	err := l.close()
+	if !l.shutdown {
+		l.shutdown = true
+		// no mill can start once shutdown is set
+		l.startMill.Do(func() {})
+		if l.millCh != nil {
+			close(l.millCh)
+		}
+	}
+	done := l.millDone
+	l.mu.Unlock()
+
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+		}
+	}
+	return err
+}
+
 // close closes the file if it is open.
 func (l *logger) close() error {
 	if l.file == nil {
@@ -223,6 +328,7 @@ func (l *logger) close() error {
 	}
 	err := l.file.Close()
 	l.file = nil
+	l.enc = nil
 	return err
 }
 
@@ -239,11 +345,22 @@ func (l *logger) Rotate() error {
 
 // rotate closes the current file, moves it aside with a timestamp in the name,
 // (if it exists), opens a new file with the original filename, and then runs
-// post-rotation processing and removal.
+// post-rotation processing and removal. Files numbered by {seq} are moved
+// aside for the next number instead, until the time key changes.
 func (l *logger) rotate() error {
 	if err := l.close(); err != nil {
 		return err
 	}
+	if l.hasSeq() {
+		if l.currentTkFileName != "" && l.currentTk == l.timeKey(l.lastLogTime) {
+			if err := l.finalize(l.currentTkFileName); err != nil {
+				return fmt.Errorf("can't rename log file: %s", err)
+			}
+			l.seq++
+		} else {
+			l.seq = 0
+		}
+	}
 	if err := l.openNew(); err != nil {
 		return err
 	}
@@ -284,8 +401,14 @@ func (l *logger) openNew() error {
 	if err != nil {
 		return fmt.Errorf("can't open new logfile: %s", err)
 	}
+	if err := l.encrypt(f, true); err != nil {
+		f.Close()
+		return err
+	}
 	l.file = f
-	l.size = 0
+	l.size = l.headerSize()
+	l.lines = 0
+	l.period = l.Rotation.periodStart(l.lastLogTime, l.TimeZone)
 	return nil
 }
 
@@ -297,16 +420,15 @@ func (l *logger) backupName() string {
 	if l.EnableTimeKey {
 		name = l.getDirTimeKey()
 	}
+	return l.backupNameFor(name)
+}
 
+// backupNameFor returns the backup name of the log file name.
+func (l *logger) backupNameFor(name string) string {
 	dir := filepath.Dir(name)
 	filename := filepath.Base(name)
 	ext := filepath.Ext(filename)
-	splitNames := strings.Split(filepath.Base(name), "_latest.log")
-	if len(splitNames) > 0 {
-		filename = splitNames[0]
-	}
-
-	prefix := filename[:len(filename)-len(ext)]
+	prefix := strings.TrimSuffix(filename[:len(filename)-len(ext)], latestSuffix)
 	t := currentTime()
 	if !l.LocalTime {
 		t = t.UTC()
@@ -319,11 +441,17 @@ func (l *logger) backupName() string {
 
 // openExistingOrNew opens the logfile if it exists and if the current write
 // would not put it over MaxSize.  If there is no such file or the write would
-// put it over the MaxSize, a new file is created.
+// put it over the MaxSize, a new file is created. The first open recovers the
+// files a crashed process left behind, see recoverFiles.
 func (l *logger) openExistingOrNew(writeLen int) error {
+	filename := l.filename()
+	if !l.recovered {
+		l.recovered = true
+		// what am I going to do, log this?
+		_, _ = l.recoverFiles(filename)
+	}
 	l.mill()
 
-	filename := l.filename()
 	info, err := osStat(filename)
 	if os.IsNotExist(err) {
 		return l.openNew()
@@ -336,22 +464,70 @@ func (l *logger) openExistingOrNew(writeLen int) error {
 		return l.rotate()
 	}
 
+	size := info.Size()
+	if l.KeyProvider != nil && size > 0 {
+		// records appended after a partial one could not be read back
+		if size, err = truncateEncrypted(filename); err != nil {
+			return l.openNew()
+		}
+	}
+
 	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		// if we fail to open the old log file for some reason, just ignore
 		// it and open a new log file.
 		return l.openNew()
 	}
+	if err := l.encrypt(file, size == 0); err != nil {
+		file.Close()
+		return err
+	}
 	l.file = file
-	l.size = info.Size()
+	l.size = size + l.headerSize()
+	l.lines = 0
+	if l.Rotation.MaxLines > 0 {
+		l.lines = l.countLines(filename)
+	}
+	// the file was last written in the period of its modification
+	l.period = l.Rotation.periodStart(info.ModTime(), l.TimeZone)
+	return nil
+}
+
+// encrypt starts encrypting writes to f if a KeyProvider is configured.
+func (l *logger) encrypt(f *os.File, newFile bool) error {
+	if l.KeyProvider == nil {
+		return nil
+	}
+	enc, err := newEncryptWriter(f, l.KeyProvider, newFile)
+	if err != nil {
+		return fmt.Errorf("can't encrypt logfile: %s", err)
+	}
+	l.enc = enc
 	return nil
 }
 
+// headerSize returns the bytes the encrypt writer wrote when opening the
+// current file.
+func (l *logger) headerSize() int64 {
+	if l.enc == nil {
+		return 0
+	}
+	return l.enc.size
+}
+
+// writeSize returns by how much writing p grows the current file.
+func (l *logger) writeSize(p []byte) int64 {
+	if l.enc != nil {
+		return l.enc.sealedSize(len(p))
+	}
+	return int64(len(p))
+}
+
 // filename generates the name of the logfile from the current time.
 func (l *logger) filename() string {
 	if l.EnableTimeKey {
 		l.currentTkFileName = l.getDirTimeKey()
-		l.currentTk = l.lastLogTime.In(l.TimeZone).Format(l.TimeKey)
+		l.currentTk = l.timeKey(l.lastLogTime)
 		return l.currentTkFileName
 	}
 	if l.Filename != "" {
@@ -366,17 +542,43 @@ func (l *logger) filename() string {
 // files are removed, keeping at most l.MaxBackups files, as long as
 // none of them are older than MaxAge.
 func (l *logger) millRunOnce() error {
+	compress, remove, err := l.millPlan()
+	if err != nil {
+		return err
+	}
+	return l.millExec(compress, remove)
+}
+
+// millExec removes and compresses the log files planned by millPlan.
+func (l *logger) millExec(compress, remove []logInfo) (err error) {
+	for _, f := range remove {
+		errRemove := os.Remove(filepath.Join(l.dir(), f.Name()))
+		if err == nil && errRemove != nil {
+			err = errRemove
+		}
+	}
+	for _, f := range compress {
+		fn := filepath.Join(l.dir(), f.Name())
+		errCompress := compressLogFileKeys(fn, fn+compressSuffix, l.KeyProvider)
+		if err == nil && errCompress != nil {
+			err = errCompress
+		}
+	}
+
+	return err
+}
+
+// millPlan returns the log files millRunOnce compresses and removes.
+func (l *logger) millPlan() (compress, remove []logInfo, err error) {
 	if l.MaxBackups == 0 && l.MaxAge == 0 && !l.Compress {
-		return nil
+		return nil, nil, nil
 	}
 
 	files, err := l.oldLogFiles()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	var compress, remove []logInfo
-
 	if l.MaxBackups > 0 && l.MaxBackups < len(files) {
 		preserved := make(map[string]bool)
 		var remaining []logInfo
@@ -420,26 +622,13 @@ func (l *logger) millRunOnce() error {
 		}
 	}
 
-	for _, f := range remove {
-		errRemove := os.Remove(filepath.Join(l.dir(), f.Name()))
-		if err == nil && errRemove != nil {
-			err = errRemove
-		}
-	}
-	for _, f := range compress {
-		fn := filepath.Join(l.dir(), f.Name())
-		errCompress := compressLogFile(fn, fn+compressSuffix)
-		if err == nil && errCompress != nil {
-			err = errCompress
-		}
-	}
-
-	return err
+	return compress, remove, nil
 }
 
 // millRun runs in a goroutine to manage post-rotation compression and removal
 // of old log files.
 func (l *logger) millRun() {
+	defer close(l.millDone)
 	for range l.millCh {
 		// what am I going to do, log this?
 		_ = l.millRunOnce()
@@ -449,8 +638,12 @@ func (l *logger) millRun() {
 // mill performs post-rotation compression and removal of stale log files,
 // starting the mill goroutine if necessary.
 func (l *logger) mill() {
+	if l.shutdown {
+		return
+	}
 	l.startMill.Do(func() {
 		l.millCh = make(chan bool, 1)
+		l.millDone = make(chan struct{})
 		go l.millRun()
 	})
 	select {
@@ -459,8 +652,10 @@ func (l *logger) mill() {
 	}
 }
 
-// oldLogFiles returns the list of backup log files stored in the same
-// directory as the current log file, sorted by ModTime
+// oldLogFiles returns the list of backup log files of l stored in the same
+// directory as the current log file, sorted by the time in their name. The
+// backups of other sinks sharing the directory, under another Filename, time
+// key layout or template, are left out.
 func (l *logger) oldLogFiles() ([]logInfo, error) {
 	files, err := ioutil.ReadDir(l.dir())
 	if err != nil {
@@ -468,22 +663,24 @@ func (l *logger) oldLogFiles() ([]logInfo, error) {
 	}
 	logFiles := []logInfo{}
 
-	prefix, ext := l.prefixAndExt()
+	var prefix string
+	if !l.EnableTimeKey {
+		prefix, _ = l.prefixAndExt()
+	}
 
 	for _, f := range files {
 		if f.IsDir() {
 			continue
 		}
-		if t, err := l.timeFromName(f.Name(), prefix, ext); err == nil {
-			logFiles = append(logFiles, logInfo{t, f})
+		lf, ok := parseLogName(f.Name())
+		if !ok || !lf.Backup() {
+			// not generated by the file sink, or not a backup file.
 			continue
 		}
-		if t, err := l.timeFromName(f.Name(), prefix, ext+compressSuffix); err == nil {
-			logFiles = append(logFiles, logInfo{t, f})
-			continue
+		// other sinks may share the directory
+		if l.ownsKey(lf.Key, prefix) {
+			logFiles = append(logFiles, logInfo{lf.Rotated, f})
 		}
-		// error parsing means that the suffix at the end was not generated
-		// by lumberjack, and therefore it's not a backup file.
 	}
 
 	sort.Sort(byFormatTime(logFiles))
@@ -491,18 +688,14 @@ func (l *logger) oldLogFiles() ([]logInfo, error) {
 	return logFiles, nil
 }
 
-// timeFromName extracts the formatted time from the filename by stripping off
-// the filename's prefix and extension. This prevents someone's filename from
-// confusing time.parse.
-func (l *logger) timeFromName(filename, prefix, ext string) (time.Time, error) {
-	if !strings.HasPrefix(filename, prefix) {
-		return time.Time{}, errors.New("mismatched prefix")
-	}
-	if !strings.HasSuffix(filename, ext) {
-		return time.Time{}, errors.New("mismatched extension")
+// ownsKey reports whether files of key are written by l, given the prefix of
+// the Filename of l.
+func (l *logger) ownsKey(key, prefix string) bool {
+	if !l.EnableTimeKey {
+		return key == prefix
 	}
-	ts := filename[len(prefix) : len(filename)-len(ext)]
-	return time.Parse(backupTimeFormat, ts)
+	_, _, ok := l.parseKey(key)
+	return ok
 }
 
 // max returns the maximum size in bytes of log files before rolling.
@@ -515,6 +708,10 @@ func (l *logger) max() int64 {
 
 // dir returns the directory for the current filename.
 func (l *logger) dir() string {
+	if l.EnableTimeKey {
+		// filename would move the current time key
+		return l.Path
+	}
 	return filepath.Dir(l.filename())
 }
 
@@ -523,24 +720,38 @@ func (l *logger) dir() string {
 func (l *logger) prefixAndExt() (prefix, ext string) {
 	filename := filepath.Base(l.filename())
 	ext = filepath.Ext(filename)
-	prefix = filename[:len(filename)-len(ext)] + "-"
+	prefix = filename[:len(filename)-len(ext)]
 	return prefix, ext
 }
 
 // getDirTimeKey returns the directory for the current time key filename.
 func (l *logger) getDirTimeKey() string {
-	return path.Join(l.Path, l.lastLogTime.In(l.TimeZone).Format(l.TimeKey)+"_latest"+".log")
+	return path.Join(l.Path, l.fileKey()+latestSuffix+logSuffix)
 }
 
 // compressLogFile compresses the given log file, removing the
 // uncompressed log file if successful.
 func compressLogFile(src, dst string) (err error) {
+	return compressLogFileKeys(src, dst, nil)
+}
+
+// compressLogFileKeys compresses the given log file, encrypting the compressed
+// file with keys when they are set. An encrypted log file is decrypted before
+// it is compressed.
+func compressLogFileKeys(src, dst string, keys KeyProvider) (err error) {
 	f, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %v", err)
 	}
 	defer f.Close()
 
+	var r io.Reader = f
+	if isEncrypted(f) {
+		if r, err = NewDecryptReader(f, keys); err != nil {
+			return fmt.Errorf("failed to decrypt log file: %v", err)
+		}
+	}
+
 	fi, err := osStat(src)
 	if err != nil {
 		return fmt.Errorf("failed to stat log file: %v", err)
@@ -558,7 +769,18 @@ func compressLogFile(src, dst string) (err error) {
 	}
 	defer gzf.Close()
 
-	gz := gzip.NewWriter(gzf)
+	var out io.Writer = gzf
+	var buf *bufio.Writer
+	if keys != nil {
+		enc, err := newEncryptWriter(gzf, keys, true)
+		if err != nil {
+			os.Remove(dst)
+			return fmt.Errorf("failed to encrypt compressed log file: %v", err)
+		}
+		buf = bufio.NewWriterSize(enc, 64*1024)
+		out = buf
+	}
+	gz := gzip.NewWriter(out)
 
 	defer func() {
 		if err != nil {
@@ -567,12 +789,17 @@ func compressLogFile(src, dst string) (err error) {
 		}
 	}()
 
-	if _, err := io.Copy(gz, f); err != nil {
+	if _, err := io.Copy(gz, r); err != nil {
 		return err
 	}
 	if err := gz.Close(); err != nil {
 		return err
 	}
+	if buf != nil {
+		if err := buf.Flush(); err != nil {
+			return err
+		}
+	}
 	if err := gzf.Close(); err != nil {
 		return err
 	}
@@ -0,0 +1,68 @@
+package clog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func touchFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(`{"message":"x"}`+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMillRunOnce_Filename(t *testing.T) {
+	fakeClock(t)
+	dir := t.TempDir()
+	touchFiles(t, dir, "app.log", "app_1704100000.log", "app_1704110000.log", "app_1704120000.log",
+		"error_1704100000.log", "error_1704110000.log")
+
+	l := NewLogFile(ConfigFile{Filename: filepath.Join(dir, "app.log"), MaxBackups: 1})
+	if err := l.millRunOnce(); err != nil {
+		t.Fatal(err)
+	}
+	want := "[app.log app_1704120000.log error_1704100000.log error_1704110000.log]"
+	if got := fmt.Sprint(dirNames(t, dir)); got != want {
+		t.Errorf("files = %s, want %s", got, want)
+	}
+}
+
+func TestMillRunOnce_SharedDirectory(t *testing.T) {
+	fakeClock(t)
+	dir := t.TempDir()
+	old := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC).Unix()
+	recent := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC).Unix()
+	touchFiles(t, dir,
+		fmt.Sprintf("a-20231201_%d.log", old), fmt.Sprintf("a-20240102_%d.log", recent),
+		fmt.Sprintf("b-20231201_%d.log", old), fmt.Sprintf("b-20240102_%d.log", recent),
+		fmt.Sprintf("2023120100_%d.log", old), fmt.Sprintf("notes_%d.log", old))
+
+	a := NewLogFile(ConfigFile{
+		Path: dir, FilenameTemplate: "{service}-{time}", Service: "a", TImeZone: time.UTC,
+		MaxAge: 7, Compress: true,
+	})
+	if err := a.millRunOnce(); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("[2023120100_%d.log a-20240102_%d.log.gz b-20231201_%d.log b-20240102_%d.log notes_%d.log]",
+		old, recent, old, recent, old)
+	if got := fmt.Sprint(dirNames(t, dir)); got != want {
+		t.Errorf("files = %s\nwant    %s", got, want)
+	}
+
+	// a time key sink only counts the backups of its layout
+	tk := NewLogFile(ConfigFile{EnableTimeKey: true, TimeKey: "2006010215", Path: dir, TImeZone: time.UTC, MaxBackups: 1})
+	files, err := tk.oldLogFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != fmt.Sprintf("2023120100_%d.log", old) {
+		t.Errorf("backups = %v", files)
+	}
+}
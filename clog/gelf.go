@@ -0,0 +1,269 @@
+package clog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ensure we always implement zerolog.LevelWriter
+var _ zerolog.LevelWriter = (*GELFWriter)(nil)
+
+const (
+	gelfVersion          = "1.1"
+	gelfDefaultChunkSize = 1420
+	gelfMaxChunkSize     = 8192
+	gelfMaxChunks        = 128
+	gelfChunkHeaderSize  = 12
+)
+
+// gelfChunkMagic starts every chunk of a chunked GELF message.
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// GELFConfig configures a GELFWriter.
+type GELFConfig struct {
+	// Network is "udp" (the default) or "tcp".
+	Network string
+
+	// Addr of the Graylog input, e.g. graylog:12201.
+	Addr string
+
+	// Host is the host field of the messages. The default is the hostname.
+	Host string
+
+	// Compress gzips the messages sent over UDP. Graylog does not accept
+	// compressed messages over TCP, so it is ignored there.
+	Compress bool
+
+	// ChunkSize is the largest UDP datagram sent, larger messages are chunked.
+	// The default is 1420 bytes, the most is 8192.
+	ChunkSize int
+
+	// Timeout of dialing and of every write. The default is 5 seconds.
+	Timeout time.Duration
+}
+
+// GELFWriter sends log events to Graylog as GELF 1.1 messages. The event
+// message becomes short_message, the other fields become additional fields.
+//
+//	gelf, err := clog.NewGELFWriter(clog.GELFConfig{Addr: "graylog:12201", Compress: true})
+//	multi := zerolog.MultiLevelWriter(os.Stdout, gelf)
+type GELFWriter struct {
+	tcp       bool
+	host      string
+	compress  bool
+	chunkSize int
+	conn      *netConn
+}
+
+func NewGELFWriter(cf GELFConfig) (*GELFWriter, error) {
+	network := cf.Network
+	if network == "" {
+		network = "udp"
+	}
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("gelf: unsupported network %q", network)
+	}
+	if cf.Addr == "" {
+		return nil, fmt.Errorf("gelf: addr is required")
+	}
+
+	chunkSize := cf.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = gelfDefaultChunkSize
+	}
+	if chunkSize > gelfMaxChunkSize {
+		chunkSize = gelfMaxChunkSize
+	}
+	if chunkSize <= gelfChunkHeaderSize {
+		return nil, fmt.Errorf("gelf: chunk size %d is too small", chunkSize)
+	}
+
+	host := cf.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	return &GELFWriter{
+		tcp:       network == "tcp",
+		host:      host,
+		compress:  cf.Compress && network == "udp",
+		chunkSize: chunkSize,
+		conn:      newNetConn(network, cf.Addr, cf.Timeout),
+	}, nil
+}
+
+// Write implements io.Writer, taking the level from the event.
+func (w *GELFWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(eventLevel(p), p)
+}
+
+// WriteLevel implements zerolog.LevelWriter.
+func (w *GELFWriter) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
+	msg, err := w.encode(level, p)
+	if err != nil {
+		return 0, err
+	}
+
+	if w.tcp {
+		err = w.conn.write(append(msg, 0))
+	} else {
+		var frames [][]byte
+		frames, err = w.chunks(msg)
+		if err == nil {
+			err = w.conn.write(frames...)
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("gelf: %s", err)
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer.
+func (w *GELFWriter) Close() error {
+	return w.conn.close()
+}
+
+// gelfFieldName matches the names Graylog accepts for additional fields.
+var gelfFieldName = regexp.MustCompile(`[^\w.\-]`)
+
+// encode converts the zerolog event p to a GELF message.
+func (w *GELFWriter) encode(level zerolog.Level, p []byte) ([]byte, error) {
+	evt, err := decodeEvent(p)
+	if err != nil {
+		return nil, err
+	}
+
+	t := sinkEventTime(p)
+	msg := map[string]interface{}{
+		"version":   gelfVersion,
+		"host":      w.host,
+		"timestamp": json.Number(strconv.FormatFloat(float64(t.UnixNano()/int64(time.Millisecond))/1000, 'f', 3, 64)),
+		"level":     syslogSeverity(level),
+	}
+	msg["short_message"] = shortMessage(evt, level)
+	if stack, ok := evt[zerolog.ErrorStackFieldName]; ok {
+		msg["full_message"] = fieldString(stack)
+	}
+
+	for k, v := range evt {
+		switch k {
+		case zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName, zerolog.ErrorStackFieldName:
+			continue
+		}
+		name := "_" + gelfFieldName.ReplaceAllString(k, "_")
+		if name == "_id" {
+			// reserved by GELF
+			name = "_id_"
+		}
+		switch v := v.(type) {
+		case nil:
+		case json.Number:
+			msg[name] = v
+		default:
+			msg[name] = fieldString(v)
+		}
+	}
+	return json.Marshal(msg)
+}
+
+// chunks returns the datagrams of msg, compressing and chunking it as needed.
+func (w *GELFWriter) chunks(msg []byte) ([][]byte, error) {
+	if w.compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(msg); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		msg = buf.Bytes()
+	}
+	if len(msg) <= w.chunkSize {
+		return [][]byte{msg}, nil
+	}
+
+	size := w.chunkSize - gelfChunkHeaderSize
+	count := (len(msg) + size - 1) / size
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("message of %d bytes needs %d chunks, at most %d are allowed", len(msg), count, gelfMaxChunks)
+	}
+
+	id := make([]byte, 8)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return nil, err
+	}
+	frames := make([][]byte, 0, count)
+	for seq := 0; seq < count; seq++ {
+		end := (seq + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+		frame := make([]byte, 0, gelfChunkHeaderSize+end-seq*size)
+		frame = append(frame, gelfChunkMagic...)
+		frame = append(frame, id...)
+		frame = append(frame, byte(seq), byte(count))
+		frame = append(frame, msg[seq*size:end]...)
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// shortMessage returns the message of evt, falling back to its error and then
+// to its level since GELF requires one.
+func shortMessage(evt map[string]interface{}, level zerolog.Level) string {
+	for _, k := range []string{zerolog.MessageFieldName, zerolog.ErrorFieldName} {
+		if s := fieldString(evt[k]); s != "" {
+			return s
+		}
+	}
+	if level == zerolog.NoLevel {
+		return "-"
+	}
+	return level.String()
+}
+
+// fieldString returns v as a string, encoding values other than strings as
+// JSON.
+func fieldString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// eventLevel returns the level of the event p, or zerolog.NoLevel.
+func eventLevel(p []byte) zerolog.Level {
+	raw, found, ok := scanField(p, zerolog.LevelFieldName)
+	if !ok || !found {
+		return zerolog.NoLevel
+	}
+	s, ok := unquoteField(raw)
+	if !ok {
+		return zerolog.NoLevel
+	}
+	level, err := zerolog.ParseLevel(s)
+	if err != nil {
+		return zerolog.NoLevel
+	}
+	return level
+}
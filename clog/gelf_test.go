@@ -0,0 +1,159 @@
+package clog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readDatagrams reads n datagrams from conn.
+func readDatagrams(t *testing.T, conn net.PacketConn, n int) [][]byte {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var out [][]byte
+	buf := make([]byte, 65536)
+	for len(out) < n {
+		m, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read datagram: %s", err)
+		}
+		out = append(out, append([]byte(nil), buf[:m]...))
+	}
+	return out
+}
+
+func TestGELFWriter_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	w, err := NewGELFWriter(GELFConfig{Addr: pc.LocalAddr().String(), Host: "node-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write([]byte(`{"level":"warn","time":1704186000,"id":"a1","user":{"name":"x"},"amount":12.5,"message":"low balance"}`))
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal(readDatagrams(t, pc, 1)[0], &msg); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"version":       "1.1",
+		"host":          "node-1",
+		"short_message": "low balance",
+		"timestamp":     1704186000.0,
+		"level":         4.0,
+		"_id_":          "a1",
+		"_user":         `{"name":"x"}`,
+		"_amount":       12.5,
+	}
+	for k, v := range want {
+		if msg[k] != v {
+			t.Errorf("%s = %v, want %v", k, msg[k], v)
+		}
+	}
+}
+
+func TestGELFWriter_UDPChunked(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	w, err := NewGELFWriter(GELFConfig{Addr: pc.LocalAddr().String(), Compress: true, ChunkSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// random enough to stay large once compressed
+	var sb strings.Builder
+	for i := 0; sb.Len() < 2000; i++ {
+		sb.WriteString(time.Duration(i * 7919).String())
+	}
+	w.Write([]byte(`{"level":"info","message":"` + sb.String() + `"}`))
+
+	first := readDatagrams(t, pc, 1)[0]
+	if !bytes.HasPrefix(first, gelfChunkMagic) {
+		t.Fatalf("datagram is not a chunk: %x", first[:2])
+	}
+	count := int(first[11])
+	chunks := append([][]byte{first}, readDatagrams(t, pc, count-1)...)
+
+	parts := make([][]byte, count)
+	for _, c := range chunks {
+		if len(c) > 100 {
+			t.Errorf("chunk of %d bytes exceeds chunk size", len(c))
+		}
+		if !bytes.Equal(c[2:10], first[2:10]) || int(c[11]) != count {
+			t.Fatalf("chunk header %x does not match %x", c[:12], first[:12])
+		}
+		parts[c[10]] = c[12:]
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(bytes.Join(parts, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg struct {
+		ShortMessage string `json:"short_message"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.ShortMessage != sb.String() {
+		t.Errorf("reassembled message does not match")
+	}
+}
+
+func TestGELFWriter_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	w, err := NewGELFWriter(GELFConfig{Network: "tcp", Addr: ln.Addr().String(), Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write([]byte(`{"level":"error","message":"one"}`))
+	w.Write([]byte(`{"level":"info","message":"two"}`))
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"one", "two"} {
+		frame, err := r.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg struct {
+			ShortMessage string `json:"short_message"`
+		}
+		if err := json.Unmarshal(frame[:len(frame)-1], &msg); err != nil {
+			t.Fatalf("frame %q: %s", frame, err)
+		}
+		if msg.ShortMessage != want {
+			t.Errorf("short_message = %q, want %q", msg.ShortMessage, want)
+		}
+	}
+}
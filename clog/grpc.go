@@ -9,6 +9,7 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"net"
 	"path"
 	"strings"
 	"time"
@@ -37,6 +38,13 @@ var (
 	IPField = "ip"
 	// IPLog gRPC client IP.
 	IPLog = true
+	// ForwardedForField key.
+	ForwardedForField = "xff"
+	// RealIPField key.
+	RealIPField = "realIP"
+	// TrustedProxies are the IPs or CIDRs of proxies whose X-Forwarded-For and
+	// X-Real-IP metadata are logged. Empty disables forwarded address logging.
+	TrustedProxies []string
 	// MetadataField key.
 	MetadataField = "md"
 	// MetadataLog gRPC call metadata.
@@ -79,26 +87,49 @@ func LogIncomingCall(ctx context.Context, logger *zerolog.Event, method string,
 	LogService(logger, method)
 	LogMethod(logger, method)
 	LogDuration(logger, t)
+	LogIP(ctx, logger)
 	LogRequest(logger, req)
 	LogIncomingMetadata(ctx, logger)
 }
 
 func SetToContext(method string) *zerolog.Logger {
-	logger := WithField(map[string]interface{}{
+	return Default().SetToContext(method)
+}
+
+// SetToContext returns a logger of l for a call of the gRPC method under a new
+// trace ID.
+func (l *Logger) SetToContext(method string) *zerolog.Logger {
+	return l.methodLogger(method, generator())
+}
+
+func (l *Logger) methodLogger(method, traceID string) *zerolog.Logger {
+	logger := l.WithField(map[string]interface{}{
 		ServiceField: path.Dir(method)[1:],
 		MethodField:  path.Base(method),
-		TraceIDField: generator(),
+		TraceIDField: traceID,
 	})
 
 	return logger
 }
 
+// incomingTraceID returns the trace ID sent by the client in the
+// TraceIDHeader metadata, or a new one.
+func incomingTraceID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if id := strings.Join(md.Get(TraceIDHeader), ""); id != "" {
+			return id
+		}
+	}
+	return generator()
+}
+
 func LogIncomingRequest(ctx context.Context, logger *zerolog.Logger, method string, t time.Time, req interface{}) {
 	log := logger.Info()
 	LogTimestamp(log, t)
 	LogService(log, method)
 	LogMethod(log, method)
 	LogDuration(log, t)
+	LogIP(ctx, log)
 	LogRequest(log, req)
 	LogIncomingMetadata(ctx, log)
 	log.Send()
@@ -148,19 +179,73 @@ func LogDuration(logger *zerolog.Event, t time.Time) {
 	}
 }
 
-// LogIP address of gRPC client, if assigned.
+// LogIP address of gRPC client, if assigned. When the peer is one of the
+// TrustedProxies, the forwarded addresses it reports are logged as well.
 //
 //	{
-//		IpField: 127.0.0.1
+//		IpField: 127.0.0.1,
+//		ForwardedForField: "203.0.113.7, 10.0.0.2",
+//		RealIPField: 203.0.113.7
 //	}
 func LogIP(ctx context.Context, logger *zerolog.Event) {
 	if IPLog {
-		if p, ok := peer.FromContext(ctx); ok {
-			*logger = *logger.Str(IPField, p.Addr.String())
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			ip := peerIP(p.Addr)
+			*logger = *logger.Str(IPField, ip)
+			if isTrustedProxy(ip) {
+				LogForwardedFor(ctx, logger)
+			}
+		}
+	}
+}
+
+// LogForwardedFor of gRPC Request, if assigned.
+//
+//	{
+//		ForwardedForField: "203.0.113.7, 10.0.0.2",
+//		RealIPField: 203.0.113.7
+//	}
+func LogForwardedFor(ctx context.Context, logger *zerolog.Event) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if xff := strings.Join(md.Get("x-forwarded-for"), ", "); xff != "" {
+			*logger = *logger.Str(ForwardedForField, xff)
+		}
+		if realIP := strings.Join(md.Get("x-real-ip"), ""); realIP != "" {
+			*logger = *logger.Str(RealIPField, realIP)
 		}
 	}
 }
 
+// peerIP strips the port from a peer address.
+func peerIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// isTrustedProxy reports whether ip matches one of TrustedProxies.
+func isTrustedProxy(ip string) bool {
+	if len(TrustedProxies) == 0 {
+		return false
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, proxy := range TrustedProxies {
+		if _, cidr, err := net.ParseCIDR(proxy); err == nil {
+			if cidr.Contains(addr) {
+				return true
+			}
+		} else if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // LogRequest in JSON of gRPC Call, given Request is smaller than MaxSize (Default=2MB).
 //
 //	{
@@ -187,12 +272,13 @@ func LogResponse(e *zerolog.Event, resp interface{}) {
 	}
 }
 
-// GetRawJSON converts a Protobuf message to JSON bytes if less than MaxSize.
+// GetRawJSON converts a Protobuf message to JSON bytes if less than MaxSize,
+// with the fields of RedactKeys redacted.
 func GetRawJSON(i interface{}) *bytes.Buffer {
 	if pb, ok := i.(proto.Message); ok {
 		b := &bytes.Buffer{}
 		if err := Marshaller.Marshal(b, pb); err == nil && b.Len() < MaxSize {
-			return b
+			return bytes.NewBuffer(RedactJSON(b.Bytes()))
 		}
 	}
 	return nil
@@ -220,7 +306,7 @@ func LogIncomingMetadata(ctx context.Context, e *zerolog.Event) {
 	}
 }
 
-// LogMetadata of gRPC Request
+// LogMetadata of gRPC Request, with the keys of RedactKeys redacted.
 //
 //	{
 //		MetadataField: {
@@ -230,6 +316,10 @@ func LogIncomingMetadata(ctx context.Context, e *zerolog.Event) {
 func LogMetadata(md *metadata.MD) *zerolog.Event {
 	dict := zerolog.Dict()
 	for i := range *md {
+		if redacted(i) {
+			dict = dict.Str(i, RedactedValue)
+			continue
+		}
 		dict = dict.Str(i, strings.Join(md.Get(i), ","))
 	}
 	return dict
@@ -259,17 +349,31 @@ func LogStatusError(logger *zerolog.Event, err error) {
 	*logger = *logger.Err(err).Str(CodeField, statusErr.Code().String()).Str(MsgField, statusErr.Message()).Interface(DetailsField, statusErr.Details())
 }
 
+// UnaryServerInterceptorWithLogger is the UnaryServerInterceptor of the
+// default Logger at the time of each call.
 func UnaryServerInterceptorWithLogger() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return Default().UnaryServerInterceptor()(ctx, req, info, handler)
+	}
+}
+
+// UnaryServerInterceptor logs every call with a request logger of l, stored
+// in the call context for GetContextLog.
+func (l *Logger) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		now := time.Now()
 
 		//grpc.SetTrailer(ctx, metadata.New(map[string]string{"my-key": "my-value2"}))
 		//grpc.SendHeader(ctx, metadata.New(map[string]string{"my-key": "my-value1"}))
-		log := SetToContext(info.FullMethod)
-		ctx = context.WithValue(ctx, CLoggerKey, log)
+		traceID := incomingTraceID(ctx)
+		log := EnrichLogger(ctx, l.methodLogger(info.FullMethod, traceID))
+		reqLog, tail := l.tailLogger(log)
+		ctx = context.WithValue(ctx, CLoggerKey, reqLog)
+		ctx = context.WithValue(ctx, CTraceIDKey, traceID)
 		LogIncomingRequest(ctx, log, info.FullMethod, now, req)
 
 		resp, err := handler(ctx, req)
+		tail.finish(log, err != nil, time.Since(now))
 		if log.Error().Enabled() {
 			if err != nil {
 				logger := log.Error()
@@ -0,0 +1,124 @@
+package clog
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func withTestLogger(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	prev := SetDefault(NewLoggerWriter(buf, zerolog.TraceLevel))
+	t.Cleanup(func() { SetDefault(prev) })
+	return buf
+}
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func peerContext(addr string, md metadata.MD) context.Context {
+	tcp, _ := net.ResolveTCPAddr("tcp", addr)
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: tcp})
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+func TestLogIP_TrustedProxy(t *testing.T) {
+	prev := TrustedProxies
+	defer func() { TrustedProxies = prev }()
+
+	md := metadata.Pairs("x-forwarded-for", "203.0.113.7", "x-real-ip", "203.0.113.7")
+	cases := []struct {
+		name    string
+		addr    string
+		proxies []string
+		wantXFF bool
+	}{
+		{"untrusted", "10.0.0.5:5000", nil, false},
+		{"cidr", "10.0.0.5:5000", []string{"10.0.0.0/8"}, true},
+		{"single ip", "10.0.0.5:5000", []string{"10.0.0.5"}, true},
+		{"other proxy", "192.168.1.1:5000", []string{"10.0.0.0/8"}, false},
+	}
+	for _, c := range cases {
+		TrustedProxies = c.proxies
+		buf := &bytes.Buffer{}
+		lg := zerolog.New(buf)
+		e := lg.Info()
+		LogIP(peerContext(c.addr, md), e)
+		e.Send()
+
+		var m map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		if host, _, _ := net.SplitHostPort(c.addr); m[IPField] != host {
+			t.Errorf("%s: ip = %v, want %s", c.name, m[IPField], host)
+		}
+		if _, ok := m[ForwardedForField]; ok != c.wantXFF {
+			t.Errorf("%s: xff logged = %v, want %v", c.name, ok, c.wantXFF)
+		}
+		if _, ok := m[RealIPField]; ok != c.wantXFF {
+			t.Errorf("%s: realIP logged = %v, want %v", c.name, ok, c.wantXFF)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor_Enrichers(t *testing.T) {
+	buf := withTestLogger(t)
+	prev := Enrichers
+	defer func() { Enrichers = prev }()
+	Enrichers = []Enricher{
+		JWTClaimEnricher("sub", "userID"),
+		MetadataEnricher("x-operator-id", "operatorID"),
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-42"}`))
+	md := metadata.Pairs(
+		"authorization", "Bearer header."+payload+".signature",
+		"x-operator-id", "op-7",
+	)
+	ctx := peerContext("127.0.0.1:4000", md)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+	_, err := UnaryServerInterceptorWithLogger()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		GetContextLog(ctx).Info().Msg("inside handler")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := decodeLines(t, buf)
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	for i, m := range lines {
+		if m["userID"] != "user-42" || m["operatorID"] != "op-7" {
+			t.Errorf("line %d missing identity fields: %v", i, m)
+		}
+	}
+	if lines[0][IPField] != "127.0.0.1" {
+		t.Errorf("request line ip = %v", lines[0][IPField])
+	}
+}
@@ -1,8 +1,13 @@
 package clog
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"fmt"
 	"github.com/rs/zerolog"
+	"io"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +18,103 @@ func decodeIfBinaryToBytes(in []byte) []byte {
 	return in
 }
 
+// OpenLogFile opens a log file written by the file sink for reading. Gzipped
+// backups are decompressed and encrypted files are decrypted with keys, which
+// may be nil for plain files.
+func OpenLogFile(name string, keys KeyProvider) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = f
+	if isEncrypted(f) {
+		if r, err = NewDecryptReader(f, keys); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	if !strings.HasSuffix(name, compressSuffix) {
+		return &logFileReader{r, f, nil}, nil
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return &logFileReader{gz, f, gz}, nil
+}
+
+// logFileReader closes both the decompressing stream and the underlying file.
+type logFileReader struct {
+	io.Reader
+	f  *os.File
+	gz *gzip.Reader
+}
+
+func (r *logFileReader) Close() error {
+	var err error
+	if r.gz != nil {
+		err = r.gz.Close()
+	}
+	if errClose := r.f.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+// eventTime returns the time of the event p, scanning the line for the
+// timestamp field and falling back to decoding the whole event when the scan
+// cannot tell.
+func eventTime(p []byte) (time.Time, error) {
+	raw, found, ok := scanField(p, zerolog.TimestampFieldName)
+	if !ok {
+		return decodeEventTime(p)
+	}
+	if !found {
+		panic("field time is missing")
+	}
+
+	switch raw[0] {
+	case '"':
+		s, ok := unquoteField(raw)
+		if !ok {
+			return decodeEventTime(p)
+		}
+		return toTime(s), nil
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return toTime(json.Number(raw)), nil
+	default:
+		// same as a decoded value toTime does not handle
+		return time.Time{}, nil
+	}
+}
+
+// decodeEventTime returns the time of the event p by decoding the whole event.
+func decodeEventTime(p []byte) (time.Time, error) {
+	evt, err := decodeEvent(p)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if _, ok := evt[zerolog.TimestampFieldName]; !ok {
+		panic("field time is missing")
+	}
+	return toTime(evt[zerolog.TimestampFieldName]), nil
+}
+
+// decodeEvent decodes the event p, keeping numbers as json.Number.
+func decodeEvent(p []byte) (map[string]interface{}, error) {
+	var evt map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(p))
+	d.UseNumber()
+	if err := d.Decode(&evt); err != nil {
+		return nil, fmt.Errorf("cannot decode event: %s", err)
+	}
+	return evt, nil
+}
+
 func toTime(i interface{}) time.Time {
 	var t time.Time
 	switch tt := i.(type) {
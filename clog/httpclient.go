@@ -0,0 +1,210 @@
+package clog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ensure we always implement http.RoundTripper
+var _ http.RoundTripper = (*Transport)(nil)
+
+var (
+	// TraceIDHeader carries the trace ID between services, over HTTP and as
+	// gRPC metadata.
+	TraceIDHeader = "X-Trace-Id"
+	// URLField key.
+	URLField = "url"
+	// ClientMessageDefault of logging outgoing HTTP calls.
+	ClientMessageDefault = "http client"
+)
+
+// Transport is an http.RoundTripper logging outgoing requests with the
+// logger of the request context, and forwarding its trace ID in
+// TraceIDHeader.
+//
+//	client := &http.Client{Transport: &clog.Transport{LogBodies: true}}
+//	req, _ := http.NewRequestWithContext(ctx, "GET", "https://partner/api", nil)
+//	resp, err := client.Do(req)
+type Transport struct {
+	// Base sends the requests. The default is http.DefaultTransport.
+	Base http.RoundTripper
+
+	// LogBodies logs the request and response bodies, up to MaxBodySize
+	// bytes each. JSON bodies are logged with RedactKeys redacted. The
+	// response is then logged once its body is read to the end or closed,
+	// so streamed responses are not held up.
+	LogBodies bool
+
+	// MaxBodySize of the logged bodies. The default is 4KB.
+	MaxBodySize int
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	now := time.Now()
+	ctx := req.Context()
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	max := t.MaxBodySize
+	if max <= 0 {
+		max = 4096
+	}
+
+	// a RoundTripper must not modify the request
+	req = req.Clone(ctx)
+	if traceID := GetTraceID(ctx); traceID != "" && req.Header.Get(TraceIDHeader) == "" {
+		req.Header.Set(TraceIDHeader, traceID)
+	}
+	var reqBody []byte
+	if t.LogBodies && req.Body != nil && req.Body != http.NoBody {
+		reqBody, req.Body = peekBody(req.Body, max)
+	}
+
+	resp, err := base.RoundTrip(req)
+
+	log := contextLog(ctx)
+	var e *zerolog.Event
+	switch {
+	case err != nil:
+		e = log.Error().Err(err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		e = log.Error()
+	default:
+		e = log.Info()
+	}
+	if !e.Enabled() {
+		return resp, err
+	}
+
+	e = e.Str(MethodField, req.Method).Str(URLField, RedactURL(req.URL))
+	if err == nil {
+		e = e.Int(StatusField, resp.StatusCode)
+	}
+	e = e.Dur(DurationField, time.Since(now))
+	if reqBody != nil {
+		e = logBody(e, ReqField, reqBody)
+	}
+	if t.LogBodies && err == nil && resp.Body != nil && resp.Body != http.NoBody {
+		// streamed responses are logged once read to the end or closed
+		resp.Body = &loggedBody{ReadCloser: resp.Body, max: max, e: e}
+		return resp, err
+	}
+	e.Msg(ClientMessageDefault)
+	return resp, err
+}
+
+// loggedBody is a response body logging event with the first max bytes read
+// from it, when it is read to the end or closed.
+type loggedBody struct {
+	io.ReadCloser
+	max int
+
+	mu  sync.Mutex
+	buf []byte
+	e   *zerolog.Event
+}
+
+// Read implements io.Reader.
+func (b *loggedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	if b.e != nil && len(b.buf) < b.max {
+		keep := n
+		if rest := b.max - len(b.buf); keep > rest {
+			keep = rest
+		}
+		b.buf = append(b.buf, p[:keep]...)
+	}
+	b.mu.Unlock()
+	if err != nil {
+		b.log()
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (b *loggedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.log()
+	return err
+}
+
+// log writes the event once.
+func (b *loggedBody) log() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.e == nil {
+		return
+	}
+	logBody(b.e, RespField, b.buf).Msg(ClientMessageDefault)
+	b.e = nil
+}
+
+// contextLog returns the logger of ctx, or the package logger.
+func contextLog(ctx context.Context) *zerolog.Logger {
+	if log, ok := ctx.Value(CLoggerKey).(*zerolog.Logger); ok {
+		return log
+	}
+	return GetLog()
+}
+
+// withTraceID adds the trace ID of ctx to e, unless the logger of ctx already
+// carries it.
+func withTraceID(ctx context.Context, e *zerolog.Event) *zerolog.Event {
+	if _, ok := ctx.Value(CLoggerKey).(*zerolog.Logger); ok {
+		return e
+	}
+	if traceID := GetTraceID(ctx); traceID != "" {
+		e = e.Str(TraceIDField, traceID)
+	}
+	return e
+}
+
+// peekBody reads up to max bytes of body, returning them with a body that
+// reads the whole content again.
+func peekBody(body io.ReadCloser, max int) ([]byte, io.ReadCloser) {
+	buf := make([]byte, max)
+	n, err := io.ReadFull(body, buf)
+	buf = buf[:n]
+
+	var rest io.Reader = body
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		rest = eofReader{}
+	default:
+		rest = errReader{err}
+	}
+	return buf, readCloser{io.MultiReader(bytes.NewReader(buf), rest), body}
+}
+
+// logBody logs b as JSON when it is a complete JSON document, and as a
+// string otherwise.
+func logBody(e *zerolog.Event, key string, b []byte) *zerolog.Event {
+	if json.Valid(b) {
+		return e.RawJSON(key, RedactJSON(b))
+	}
+	return e.Bytes(key, RedactText(b))
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) { return 0, io.EOF }
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
@@ -0,0 +1,95 @@
+package clog
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	buf := withTestLogger(t)
+
+	var gotTrace string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTrace = r.Header.Get(TraceIDHeader)
+		b, _ := io.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	ctx := context.WithValue(context.Background(), CLoggerKey, WithField(map[string]interface{}{TraceIDField: "abc"}))
+	ctx = context.WithValue(ctx, CTraceIDKey, "abc")
+	body := `{"user":"u1","password":"hunter2","padding":"` + strings.Repeat("x", 100) + `"}`
+	req, _ := http.NewRequestWithContext(ctx, "POST", srv.URL+"/login?user=u1&token=s3cret", strings.NewReader(body))
+
+	client := &http.Client{Transport: &Transport{LogBodies: true, MaxBodySize: 64}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echoed, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if gotTrace != "abc" {
+		t.Errorf("trace header = %q, want abc", gotTrace)
+	}
+	if string(echoed) != body {
+		t.Errorf("server received %q, want the whole body", echoed)
+	}
+	if req.Header.Get(TraceIDHeader) != "" {
+		t.Error("the caller's request was modified")
+	}
+
+	lines := decodeLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+	m := lines[0]
+	if m[TraceIDField] != "abc" || m[MethodField] != "POST" || m[StatusField] != 200.0 {
+		t.Errorf("line = %v", m)
+	}
+	if u := m[URLField].(string); strings.Contains(u, "s3cret") || !strings.Contains(u, "user=u1") {
+		t.Errorf("url = %s, want the token redacted", u)
+	}
+	if s, _ := m[ReqField].(string); !strings.HasPrefix(s, `{"user":"u1","password":"[REDACTED]","padding":"xxx`) || strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("req = %v, want the truncated body without the password", m[ReqField])
+	}
+}
+
+func TestTransport_StreamedResponse(t *testing.T) {
+	buf := withTestLogger(t)
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "data: 2\n\n")
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &Transport{LogBodies: true}}
+	resp, err := client.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the response is back while the server still streams
+	if buf.Len() != 0 {
+		t.Errorf("logged before the body was read: %s", buf.Bytes())
+	}
+	close(release)
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	lines := decodeLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+	if s, _ := lines[0][RespField].(string); s != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("resp = %q, want the streamed body", lines[0][RespField])
+	}
+}
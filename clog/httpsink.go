@@ -0,0 +1,667 @@
+package clog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPEncoder turns a batch of log events into the body of one request.
+type HTTPEncoder interface {
+	ContentType() string
+	Encode(events [][]byte) ([]byte, error)
+}
+
+// HTTPBatchResult is implemented by the HTTPEncoders of endpoints that may
+// accept a batch in part, answering with a success status.
+type HTTPBatchResult interface {
+	// Result returns the indexes of the events of the batch the endpoint
+	// failed in the response body: those worth retrying, and those rejected
+	// for good, such as mapping errors.
+	Result(body []byte) (retry, rejected []int, err error)
+}
+
+// LokiEncoder encodes batches for the Loki push API (/loki/api/v1/push).
+type LokiEncoder struct {
+	// Labels are added to every stream, e.g. {"app": "wallet"}.
+	Labels map[string]string
+
+	// LabelFields are event fields used as stream labels, e.g. "level".
+	// Keep them to fields with few distinct values.
+	LabelFields []string
+}
+
+func (LokiEncoder) ContentType() string {
+	return "application/json"
+}
+
+func (e LokiEncoder) Encode(events [][]byte) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	var streams []*stream
+	byLabels := map[string]*stream{}
+
+	for _, evt := range events {
+		labels := make(map[string]string, len(e.Labels)+len(e.LabelFields))
+		for k, v := range e.Labels {
+			labels[k] = v
+		}
+		var key strings.Builder
+		for _, field := range e.LabelFields {
+			raw, found, _ := scanField(evt, field)
+			if !found {
+				continue
+			}
+			v, ok := unquoteField(raw)
+			if !ok {
+				v = string(raw)
+			}
+			labels[field] = v
+			key.WriteString(field + "=" + v + "\x00")
+		}
+
+		s, ok := byLabels[key.String()]
+		if !ok {
+			s = &stream{Stream: labels}
+			byLabels[key.String()] = s
+			streams = append(streams, s)
+		}
+		ts := strconv.FormatInt(sinkEventTime(evt).UnixNano(), 10)
+		s.Values = append(s.Values, [2]string{ts, string(bytes.TrimRight(evt, "\n"))})
+	}
+
+	return json.Marshal(map[string]interface{}{"streams": streams})
+}
+
+// ElasticEncoder encodes batches for the Elasticsearch _bulk API.
+type ElasticEncoder struct {
+	// Index the events are added to. It may hold a Go time layout between
+	// braces, e.g. "logs-{2006.01.02}", formatted with the event time in UTC.
+	Index string
+}
+
+func (ElasticEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (e ElasticEncoder) Encode(events [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, evt := range events {
+		action, err := json.Marshal(map[string]map[string]string{
+			"create": {"_index": e.index(evt)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(bytes.TrimRight(evt, "\n"))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// Result implements HTTPBatchResult, reading the status of every item of a
+// _bulk response. Items failed with a retryStatus are retried.
+func (ElasticEncoder) Result(body []byte) (retry, rejected []int, err error) {
+	var resp struct {
+		Errors bool                              `json:"errors"`
+		Items  []map[string]struct{ Status int } `json:"items"`
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil, nil
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, nil, fmt.Errorf("invalid bulk response: %s", err)
+	}
+	if !resp.Errors {
+		return nil, nil, nil
+	}
+	for i, item := range resp.Items {
+		for _, result := range item {
+			switch {
+			case retryStatus(result.Status):
+				retry = append(retry, i)
+			case result.Status < 200 || result.Status > 299:
+				rejected = append(rejected, i)
+			}
+		}
+	}
+	return retry, rejected, nil
+}
+
+// retryStatus reports whether a request failed with status may succeed when
+// sent again. Other client errors are final.
+func retryStatus(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// index returns the index of evt.
+func (e ElasticEncoder) index(evt []byte) string {
+	start := strings.IndexByte(e.Index, '{')
+	end := strings.IndexByte(e.Index, '}')
+	if start < 0 || end < start {
+		return e.Index
+	}
+	layout := e.Index[start+1 : end]
+	return e.Index[:start] + sinkEventTime(evt).UTC().Format(layout) + e.Index[end+1:]
+}
+
+// sinkEventTime returns the time of evt, or the current time if it has none.
+func sinkEventTime(evt []byte) time.Time {
+	raw, found, ok := scanField(evt, zerolog.TimestampFieldName)
+	if !ok || !found {
+		return time.Now()
+	}
+	if s, ok := unquoteField(raw); ok {
+		if t, err := time.Parse(zerolog.TimeFieldFormat, s); err == nil {
+			return t
+		}
+		return time.Now()
+	}
+	if _, err := json.Number(raw).Int64(); err != nil {
+		return time.Now()
+	}
+	return toTime(json.Number(raw))
+}
+
+// HTTPSinkConfig configures an HTTPSink.
+type HTTPSinkConfig struct {
+	// URL the batches are posted to, e.g. http://loki:3100/loki/api/v1/push
+	// or http://elasticsearch:9200/_bulk.
+	URL string
+
+	// Encoder of the request bodies.
+	Encoder HTTPEncoder
+
+	// Headers are added to every request, e.g. Authorization.
+	Headers map[string]string
+
+	// Client sends the requests. It defaults to a client with a 10 second
+	// timeout.
+	Client *http.Client
+
+	// BatchSize is the maximum number of events of a batch. It defaults to 500.
+	BatchSize int
+
+	// BatchBytes is the maximum size of the events of a batch. It defaults to
+	// 1 megabyte.
+	BatchBytes int
+
+	// FlushInterval is the longest time an event waits for its batch to be
+	// sent. It defaults to 1 second.
+	FlushInterval time.Duration
+
+	// MaxRetries of a failed batch before it is spilled. It defaults to 3;
+	// a negative value disables retries.
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry, doubled on every
+	// following one. It defaults to 200 milliseconds.
+	RetryBackoff time.Duration
+
+	// SpillDir is the directory batches are kept in while the endpoint is
+	// down. They are sent again, oldest first, once it is back. Failed
+	// batches are dropped when empty.
+	SpillDir string
+
+	// SpillMaxBytes caps the size of SpillDir, dropping the oldest batches.
+	// It defaults to 100 megabytes.
+	SpillMaxBytes int64
+
+	// QueueSize is the number of events waiting to be batched. Events are
+	// dropped when the queue is full. It defaults to 10000.
+	QueueSize int
+}
+
+// HTTPSinkStats are the counters of an HTTPSink.
+type HTTPSinkStats struct {
+	Sent     uint64
+	Dropped  uint64
+	Spilled  uint64
+	Replayed uint64
+	Failures uint64
+
+	// Rejected are the events the endpoint refused for good, see
+	// HTTPBatchResult.
+	Rejected uint64
+}
+
+// ensure we always implement io.WriteCloser
+var _ io.WriteCloser = (*HTTPSink)(nil)
+
+// HTTPSink sends log events in batches to an HTTP endpoint such as Loki or
+// Elasticsearch.
+//
+//	sink := clog.NewHTTPSink(clog.HTTPSinkConfig{
+//		URL:      "http://loki:3100/loki/api/v1/push",
+//		Encoder:  clog.LokiEncoder{Labels: map[string]string{"app": "wallet"}, LabelFields: []string{"level"}},
+//		SpillDir: "./logs/spill",
+//	})
+//	defer sink.Close()
+//	multi := zerolog.MultiLevelWriter(os.Stdout, sink)
+type HTTPSink struct {
+	cfg     HTTPSinkConfig
+	queue   chan []byte
+	flushCh chan chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	close   sync.Once
+
+	// mu orders the writes before Close, so none is queued once the queue
+	// is drained.
+	mu     sync.RWMutex
+	closed bool
+
+	spillSeq uint64
+	pending  bool
+
+	sent, dropped, spilled, replayed, failures, rejected uint64
+}
+
+func NewHTTPSink(cfg HTTPSinkConfig) *HTTPSink {
+	if cfg.URL == "" || cfg.Encoder == nil {
+		panic("url and encoder are required")
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.BatchBytes <= 0 {
+		cfg.BatchBytes = int(megabytes)
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 200 * time.Millisecond
+	}
+	if cfg.SpillMaxBytes <= 0 {
+		cfg.SpillMaxBytes = 100 * megabytes
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+
+	s := &HTTPSink{
+		cfg:     cfg,
+		queue:   make(chan []byte, cfg.QueueSize),
+		flushCh: make(chan chan struct{}),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	// batches spilled by an earlier run are replayed first
+	s.pending = len(s.spillFiles()) > 0
+	go s.run()
+	return s
+}
+
+// Write implements io.Writer, queueing a copy of the event p.
+func (s *HTTPSink) Write(p []byte) (int, error) {
+	evt := make([]byte, len(p))
+	copy(evt, p)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return 0, errors.New("http sink is closed")
+	}
+	select {
+	case s.queue <- evt:
+		return len(p), nil
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+		return 0, errors.New("http sink queue is full")
+	}
+}
+
+// Flush sends the queued events and waits for it to finish.
+func (s *HTTPSink) Flush() {
+	ack := make(chan struct{})
+	select {
+	case s.flushCh <- ack:
+		<-ack
+	case <-s.stopped:
+	}
+}
+
+// Close implements io.Closer, sending the queued events before it returns.
+// Later writes fail.
+func (s *HTTPSink) Close() error {
+	s.stop()
+	<-s.stopped
+	return nil
+}
+
+// Shutdown sends the queued events until ctx is done.
+func (s *HTTPSink) Shutdown(ctx context.Context) error {
+	s.stop()
+	select {
+	case <-s.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop refuses the next writes and stops the batching once the queue is
+// drained.
+func (s *HTTPSink) stop() {
+	s.close.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		close(s.done)
+		s.mu.Unlock()
+	})
+}
+
+// Stats returns the counters of s.
+func (s *HTTPSink) Stats() HTTPSinkStats {
+	return HTTPSinkStats{
+		Sent:     atomic.LoadUint64(&s.sent),
+		Dropped:  atomic.LoadUint64(&s.dropped),
+		Spilled:  atomic.LoadUint64(&s.spilled),
+		Replayed: atomic.LoadUint64(&s.replayed),
+		Failures: atomic.LoadUint64(&s.failures),
+		Rejected: atomic.LoadUint64(&s.rejected),
+	}
+}
+
+// run batches the queued events until the sink is closed.
+func (s *HTTPSink) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var batch [][]byte
+	size := 0
+	flush := func() {
+		s.flush(batch)
+		batch, size = nil, 0
+	}
+	add := func(evt []byte) {
+		batch = append(batch, evt)
+		size += len(evt)
+		if len(batch) >= s.cfg.BatchSize || size >= s.cfg.BatchBytes {
+			flush()
+		}
+	}
+
+	for {
+		select {
+		case evt := <-s.queue:
+			add(evt)
+		case <-ticker.C:
+			flush()
+		case ack := <-s.flushCh:
+			s.drain(add)
+			flush()
+			close(ack)
+		case <-s.done:
+			s.drain(add)
+			flush()
+			return
+		}
+	}
+}
+
+// drain batches the events left in the queue.
+func (s *HTTPSink) drain(add func([]byte)) {
+	for {
+		select {
+		case evt := <-s.queue:
+			add(evt)
+		default:
+			return
+		}
+	}
+}
+
+// flush sends batch, keeping the order of the spilled batches: while some are
+// waiting, batch is spilled after them and the spill is replayed.
+func (s *HTTPSink) flush(batch [][]byte) {
+	if len(batch) > 0 {
+		if s.pending {
+			s.spill(batch)
+		} else if failed, err := s.sendRetry(batch); err != nil {
+			atomic.AddUint64(&s.failures, 1)
+			if len(failed) > 0 {
+				s.spill(failed)
+			}
+		}
+	}
+	if s.pending {
+		s.replay()
+	}
+}
+
+// sendRetry sends batch, retrying the events that failed with exponential
+// backoff. It returns the events still failed after the last retry.
+func (s *HTTPSink) sendRetry(batch [][]byte) ([][]byte, error) {
+	backoff := s.cfg.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= s.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if batch, err = s.send(batch); err == nil || len(batch) == 0 {
+			return batch, err
+		}
+	}
+	return batch, err
+}
+
+// send posts batch, counting the events sent and rejected. It returns the
+// events to send again: all of them when the request failed, none when the
+// endpoint refused the batch for good.
+func (s *HTTPSink) send(batch [][]byte) ([][]byte, error) {
+	body, err := s.cfg.Encoder.Encode(batch)
+	if err != nil {
+		return batch, err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return batch, err
+	}
+	req.Header.Set("Content-Type", s.cfg.Encoder.ContentType())
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return batch, err
+	}
+	defer resp.Body.Close()
+	result, ok := s.cfg.Encoder.(HTTPBatchResult)
+	if !ok || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+			atomic.AddUint64(&s.sent, uint64(len(batch)))
+			return nil, nil
+		case retryStatus(resp.StatusCode):
+			return batch, fmt.Errorf("http sink: %s", resp.Status)
+		default:
+			atomic.AddUint64(&s.rejected, uint64(len(batch)))
+			return nil, fmt.Errorf("http sink: batch rejected: %s", resp.Status)
+		}
+	}
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64*megabytes))
+	if err != nil {
+		return batch, err
+	}
+	retry, rejected, err := result.Result(respBody)
+	if err != nil {
+		return batch, err
+	}
+	failed := make([][]byte, 0, len(retry))
+	for _, i := range retry {
+		if i >= 0 && i < len(batch) {
+			failed = append(failed, batch[i])
+		}
+	}
+	atomic.AddUint64(&s.rejected, uint64(len(rejected)))
+	atomic.AddUint64(&s.sent, uint64(len(batch)-len(failed)-len(rejected)))
+	if len(failed) > 0 {
+		return failed, fmt.Errorf("http sink: %d of %d events failed", len(failed), len(batch))
+	}
+	return nil, nil
+}
+
+// spill writes batch to SpillDir, or drops it when there is none.
+func (s *HTTPSink) spill(batch [][]byte) {
+	if s.cfg.SpillDir == "" {
+		atomic.AddUint64(&s.dropped, uint64(len(batch)))
+		return
+	}
+	if err := os.MkdirAll(s.cfg.SpillDir, 0755); err != nil {
+		atomic.AddUint64(&s.dropped, uint64(len(batch)))
+		return
+	}
+
+	s.spillSeq++
+	name := filepath.Join(s.cfg.SpillDir, fmt.Sprintf("%020d-%06d.batch", time.Now().UnixNano(), s.spillSeq%1000000))
+	if err := os.WriteFile(name, joinBatch(batch), 0600); err != nil {
+		atomic.AddUint64(&s.dropped, uint64(len(batch)))
+		return
+	}
+	atomic.AddUint64(&s.spilled, uint64(len(batch)))
+	s.pending = true
+	s.trimSpill()
+}
+
+// spillFiles returns the spilled batches, oldest first.
+func (s *HTTPSink) spillFiles() []os.DirEntry {
+	if s.cfg.SpillDir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(s.cfg.SpillDir)
+	if err != nil {
+		return nil
+	}
+	var files []os.DirEntry
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".batch") {
+			files = append(files, e)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	return files
+}
+
+// trimSpill removes the oldest batches once SpillDir is over SpillMaxBytes.
+func (s *HTTPSink) trimSpill() {
+	files := s.spillFiles()
+	var total int64
+	sizes := make([]int64, len(files))
+	for i, f := range files {
+		if info, err := f.Info(); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+	for i := 0; i < len(files) && total > s.cfg.SpillMaxBytes; i++ {
+		name := filepath.Join(s.cfg.SpillDir, files[i].Name())
+		if n, err := countLines(name); err == nil {
+			atomic.AddUint64(&s.dropped, uint64(n))
+		}
+		if os.Remove(name) == nil {
+			total -= sizes[i]
+		}
+	}
+}
+
+// replay sends the spilled batches, oldest first, stopping at the first
+// failure. Replays are not retried; the next flush tries again. Batches the
+// endpoint refused for good are removed.
+func (s *HTTPSink) replay() {
+	for _, f := range s.spillFiles() {
+		name := filepath.Join(s.cfg.SpillDir, f.Name())
+		batch, err := readBatch(name)
+		if err != nil {
+			os.Remove(name)
+			continue
+		}
+		failed, err := s.send(batch)
+		if err != nil {
+			atomic.AddUint64(&s.failures, 1)
+		}
+		if len(failed) > 0 {
+			if len(failed) < len(batch) {
+				// keep the events that failed only
+				atomic.AddUint64(&s.replayed, uint64(len(batch)-len(failed)))
+				_ = os.WriteFile(name, joinBatch(failed), 0600)
+			}
+			return
+		}
+		os.Remove(name)
+		atomic.AddUint64(&s.replayed, uint64(len(batch)))
+	}
+	s.pending = false
+}
+
+// joinBatch returns the lines of the events of batch.
+func joinBatch(batch [][]byte) []byte {
+	var buf bytes.Buffer
+	for _, evt := range batch {
+		buf.Write(bytes.TrimRight(evt, "\n"))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func readBatch(name string) ([][]byte, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var batch [][]byte
+	for _, line := range bytes.Split(b, []byte{'\n'}) {
+		if len(line) > 0 {
+			batch = append(batch, line)
+		}
+	}
+	return batch, nil
+}
+
+func countLines(name string) (int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n := 0
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16*1024*1024)
+	for sc.Scan() {
+		n++
+	}
+	return n, sc.Err()
+}
@@ -0,0 +1,254 @@
+package clog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// recorder is an httptest handler keeping the bodies it receives.
+type recorder struct {
+	mu     sync.Mutex
+	bodies [][]byte
+	down   int32
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&rec.down) == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	b, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	rec.bodies = append(rec.bodies, b)
+	rec.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rec *recorder) get() [][]byte {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([][]byte(nil), rec.bodies...)
+}
+
+func TestHTTPSink_Loki(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	sink := NewHTTPSink(HTTPSinkConfig{
+		URL:           srv.URL,
+		Encoder:       LokiEncoder{Labels: map[string]string{"app": "wallet"}, LabelFields: []string{"level"}},
+		BatchSize:     3,
+		FlushInterval: time.Hour,
+	})
+	lg := zerolog.New(sink).With().Timestamp().Logger()
+	for i := 0; i < 7; i++ {
+		lg.Info().Int("i", i).Msg("hello")
+	}
+	lg.Error().Msg("boom")
+	sink.Close()
+
+	bodies := rec.get()
+	if len(bodies) != 3 {
+		t.Fatalf("got %d requests, want 3", len(bodies))
+	}
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(bodies[2], &push); err != nil {
+		t.Fatal(err)
+	}
+	if len(push.Streams) != 2 {
+		t.Fatalf("got %d streams, want info and error", len(push.Streams))
+	}
+	if s := push.Streams[1]; s.Stream["app"] != "wallet" || s.Stream["level"] != "error" || len(s.Values) != 1 {
+		t.Errorf("error stream = %+v", s)
+	}
+	if stats := sink.Stats(); stats.Sent != 8 || stats.Dropped != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestHTTPSink_ElasticBulk(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	sink := NewHTTPSink(HTTPSinkConfig{
+		URL:           srv.URL,
+		Encoder:       ElasticEncoder{Index: "logs-{2006.01.02}"},
+		FlushInterval: 10 * time.Millisecond,
+	})
+	defer sink.Close()
+	sink.Write([]byte(`{"level":"info","time":1704186000,"message":"a"}` + "\n"))
+	sink.Flush()
+
+	bodies := rec.get()
+	if len(bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(bodies))
+	}
+	want := `{"create":{"_index":"logs-2024.01.02"}}` + "\n" + `{"level":"info","time":1704186000,"message":"a"}` + "\n"
+	if string(bodies[0]) != want {
+		t.Errorf("body = %q, want %q", bodies[0], want)
+	}
+}
+
+func TestHTTPSink_SpillAndReplay(t *testing.T) {
+	rec := &recorder{down: 1}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	sink := NewHTTPSink(HTTPSinkConfig{
+		URL:           srv.URL,
+		Encoder:       ElasticEncoder{Index: "logs"},
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		MaxRetries:    1,
+		RetryBackoff:  time.Millisecond,
+		SpillDir:      t.TempDir(),
+	})
+	defer sink.Close()
+
+	for i := 0; i < 4; i++ {
+		fmt.Fprintf(sink, `{"time":1704186000,"i":%d}`+"\n", i)
+	}
+	sink.Flush()
+	if stats := sink.Stats(); stats.Spilled != 4 || stats.Sent != 0 {
+		t.Fatalf("while down: stats = %+v", stats)
+	}
+
+	atomic.StoreInt32(&rec.down, 0)
+	fmt.Fprintf(sink, `{"time":1704186000,"i":%d}`+"\n", 4)
+	sink.Flush()
+
+	var order []int
+	for _, body := range rec.get() {
+		sc := bufio.NewScanner(bytes.NewReader(body))
+		for sc.Scan() {
+			var evt struct{ I *int }
+			if json.Unmarshal(sc.Bytes(), &evt) == nil && evt.I != nil {
+				order = append(order, *evt.I)
+			}
+		}
+	}
+	if fmt.Sprint(order) != "[0 1 2 3 4]" {
+		t.Errorf("received %v, want events in order", order)
+	}
+	if stats := sink.Stats(); stats.Replayed != 5 || stats.Sent != 5 {
+		t.Errorf("after replay: stats = %+v", stats)
+	}
+}
+
+func TestHTTPSink_RejectedBatch(t *testing.T) {
+	var down int32 = 1
+	rec := &recorder{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		if bytes.Contains(b, []byte(`"i":0`)) {
+			// e.g. Loki refusing entries that are too old
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		rec.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	sink := NewHTTPSink(HTTPSinkConfig{
+		URL:           srv.URL,
+		Encoder:       ElasticEncoder{Index: "logs"},
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		RetryBackoff:  time.Millisecond,
+		SpillDir:      t.TempDir(),
+	})
+	defer sink.Close()
+
+	for i := 0; i < 2; i++ {
+		fmt.Fprintf(sink, `{"time":1704186000,"i":%d}`+"\n", i)
+		sink.Flush()
+	}
+	if stats := sink.Stats(); stats.Spilled != 2 {
+		t.Fatalf("while down: stats = %+v", stats)
+	}
+
+	// the rejected batch is dropped instead of blocking the one behind it
+	atomic.StoreInt32(&down, 0)
+	sink.Flush()
+	if got := rec.get(); len(got) != 1 || !bytes.Contains(got[0], []byte(`"i":1`)) {
+		t.Errorf("received %q, want the second batch", got)
+	}
+	if stats := sink.Stats(); stats.Rejected != 1 || stats.Sent != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if files := sink.spillFiles(); len(files) != 0 {
+		t.Errorf("spill files left: %d", len(files))
+	}
+
+	fmt.Fprintf(sink, `{"time":1704186000,"i":%d}`+"\n", 0)
+	sink.Flush()
+	if stats := sink.Stats(); stats.Rejected != 2 || stats.Spilled != 2 {
+		t.Errorf("rejected batch retried or spilled: stats = %+v", stats)
+	}
+}
+
+func TestHTTPSink_ElasticPartialFailure(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		first := len(bodies) == 1
+		mu.Unlock()
+		if first {
+			fmt.Fprint(w, `{"errors":true,"items":[{"create":{"status":201}},{"create":{"status":429}},{"create":{"status":400}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"errors":false,"items":[{"create":{"status":201}}]}`)
+	}))
+	defer srv.Close()
+
+	sink := NewHTTPSink(HTTPSinkConfig{
+		URL:           srv.URL,
+		Encoder:       ElasticEncoder{Index: "logs"},
+		FlushInterval: time.Hour,
+		RetryBackoff:  time.Millisecond,
+	})
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(sink, `{"time":1704186000,"i":%d}`+"\n", i)
+	}
+	sink.Close()
+
+	if stats := sink.Stats(); stats.Sent != 2 || stats.Rejected != 1 || stats.Failures != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 2 || bodies[1] != `{"create":{"_index":"logs"}}`+"\n"+`{"time":1704186000,"i":1}`+"\n" {
+		t.Errorf("requests = %q, want the failed event retried alone", bodies)
+	}
+
+	if _, err := sink.Write([]byte(`{"i":3}`)); err == nil {
+		t.Error("write after close should fail")
+	}
+}
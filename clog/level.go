@@ -0,0 +1,76 @@
+package clog
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog"
+)
+
+// ensure we always implement zerolog.LevelWriter
+var _ zerolog.LevelWriter = (*LevelFilter)(nil)
+
+// LevelFilter passes to its sink only the events with a level between a
+// minimum and a maximum, both included. Events without a level rank above
+// panic, so they pass any minimum but no maximum.
+//
+//	errors := clog.NewLevelFilter(clog.NewLogFile(errorFile), zerolog.ErrorLevel, zerolog.PanicLevel)
+//	multi := zerolog.MultiLevelWriter(os.Stdout, errors)
+type LevelFilter struct {
+	w        io.Writer
+	min, max zerolog.Level
+}
+
+func NewLevelFilter(w io.Writer, min, max zerolog.Level) *LevelFilter {
+	return &LevelFilter{w: w, min: min, max: max}
+}
+
+// Write implements io.Writer, taking the level from the event.
+func (f *LevelFilter) Write(p []byte) (n int, err error) {
+	return f.WriteLevel(eventLevel(p), p)
+}
+
+// WriteLevel implements zerolog.LevelWriter.
+func (f *LevelFilter) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
+	if level < f.min || level > f.max {
+		return len(p), nil
+	}
+	if lw, ok := f.w.(zerolog.LevelWriter); ok {
+		return lw.WriteLevel(level, p)
+	}
+	return f.w.Write(p)
+}
+
+// Close implements io.Closer, closing the sink when it is a closer.
+func (f *LevelFilter) Close() error {
+	if c, ok := f.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Shutdown shuts the sink down within ctx.
+func (f *LevelFilter) Shutdown(ctx context.Context) error {
+	return shutdownSink(ctx, f.w)
+}
+
+// levelRange parses the bounds of a level range, an empty bound being open.
+func levelRange(min, max string) (zerolog.Level, zerolog.Level, error) {
+	lo, hi := zerolog.TraceLevel, zerolog.NoLevel
+	var err error
+	if min != "" {
+		if lo, err = zerolog.ParseLevel(min); err != nil {
+			return 0, 0, fmt.Errorf("min level: %s", err)
+		}
+	}
+	if max != "" {
+		if hi, err = zerolog.ParseLevel(max); err != nil {
+			return 0, 0, fmt.Errorf("max level: %s", err)
+		}
+	}
+	if lo > hi {
+		return 0, 0, fmt.Errorf("min level %s is above max level %s", lo, hi)
+	}
+	return lo, hi, nil
+}
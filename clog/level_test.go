@@ -0,0 +1,75 @@
+package clog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestLevelFilter(t *testing.T) {
+	var buf bytes.Buffer
+	lg := zerolog.New(NewLevelFilter(&buf, zerolog.InfoLevel, zerolog.WarnLevel))
+	lg.Debug().Msg("debug")
+	lg.Info().Msg("info")
+	lg.Warn().Msg("warn")
+	lg.Error().Msg("error")
+	lg.Log().Msg("nolevel")
+
+	got := buf.String()
+	for _, want := range []string{`"info"`, `"warn"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in %q", want, got)
+		}
+	}
+	for _, unwanted := range []string{`"debug"`, `"error"`, `"nolevel"`} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("unexpected %s in %q", unwanted, got)
+		}
+	}
+
+	// plain writes take the level from the event
+	buf.Reset()
+	f := NewLevelFilter(&buf, zerolog.ErrorLevel, zerolog.PanicLevel)
+	f.Write([]byte(`{"level":"info","message":"a"}` + "\n"))
+	f.Write([]byte(`{"level":"fatal","message":"b"}` + "\n"))
+	if got := buf.String(); got != `{"level":"fatal","message":"b"}`+"\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNewWithOptions_Files(t *testing.T) {
+	defer SetDefault(Default())
+
+	dir := t.TempDir()
+	err := NewWithOptions(Options{
+		Format: FormatJson,
+		Files: []FileSink{
+			{ConfigFile: ConfigFile{Filename: filepath.Join(dir, "error.log"), MaxAge: 90}, MinLevel: "error"},
+			{ConfigFile: ConfigFile{Filename: filepath.Join(dir, "app.log"), MaxAge: 5}, MaxLevel: "warn"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	GetLog().Info().Msg("hello")
+	GetLog().Error().Msg("boom")
+
+	for name, want := range map[string]string{"error.log": "boom", "app.log": "hello"} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], want) {
+			t.Errorf("%s = %q, want only %q", name, b, want)
+		}
+	}
+
+	err = NewWithOptions(Options{Files: []FileSink{{MinLevel: "error", MaxLevel: "info"}}})
+	if err == nil {
+		t.Error("want an error for an empty level range")
+	}
+}
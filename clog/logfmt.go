@@ -0,0 +1,125 @@
+package clog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/rs/zerolog"
+)
+
+// ensure we always implement zerolog.LevelWriter
+var _ zerolog.LevelWriter = (*LogfmtWriter)(nil)
+
+// LogfmtWriter writes events as logfmt key=value lines, leading with the
+// time, level, message and caller.
+//
+//	time=2024-01-02T15:04:05+07:00 level=info message="user created" caller=main.go:12 id=42
+type LogfmtWriter struct {
+	w io.Writer
+}
+
+func NewLogfmtWriter(w io.Writer) *LogfmtWriter {
+	return &LogfmtWriter{w: w}
+}
+
+// Write implements io.Writer.
+func (l *LogfmtWriter) Write(p []byte) (n int, err error) {
+	evt, err := decodeEvent(p)
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	for _, k := range eventKeys(evt, []string{zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName, zerolog.CallerFieldName}) {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(logfmtKey(k))
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(evt[k]))
+	}
+	buf.WriteByte('\n')
+	if _, err := l.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteLevel implements zerolog.LevelWriter.
+func (l *LogfmtWriter) WriteLevel(_ zerolog.Level, p []byte) (n int, err error) {
+	return l.Write(p)
+}
+
+// eventKeys returns the keys of evt, those of first in order and then the
+// others sorted.
+func eventKeys(evt map[string]interface{}, first []string) []string {
+	keys := make([]string, 0, len(evt))
+	seen := make(map[string]bool, len(first))
+	for _, k := range first {
+		if _, ok := evt[k]; ok && !seen[k] {
+			keys = append(keys, k)
+		}
+		seen[k] = true
+	}
+	rest := len(keys)
+	for k := range evt {
+		if !seen[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys[rest:])
+	return keys
+}
+
+// logfmtKey strips the characters logfmt keys cannot hold.
+func logfmtKey(k string) string {
+	b := make([]byte, 0, len(k))
+	for i := 0; i < len(k); i++ {
+		if c := k[i]; c > ' ' && c != '=' && c != '"' && c < 0x7f {
+			b = append(b, c)
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+// logfmtValue formats v, quoting it when needed. Objects and arrays are
+// written as JSON.
+func logfmtValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		s = v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return strconv.Quote(err.Error())
+		}
+		s = string(b)
+	}
+	if s == "" || logfmtQuote(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// logfmtQuote reports whether s must be quoted.
+func logfmtQuote(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == '=' || c == '"' || c == '\\' || c >= 0x7f {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package clog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestLogfmtWriter(t *testing.T) {
+	var buf bytes.Buffer
+	lg := zerolog.New(NewLogfmtWriter(&buf))
+	lg.Info().Int("id", 42).Str("name", "a b").Str("empty", "").Bool("ok", true).
+		Interface("tags", []string{"x"}).Str("a=b", "c").Msg("user created")
+
+	want := `level=info message="user created" ab=c empty="" id=42 name="a b" ok=true tags="[\"x\"]"` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,205 @@
+package clog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+// Logger is a configured logger owning its sinks. The package functions, such
+// as GetLog and WithField, use the default Logger set up by New.
+//
+//	lg, err := clog.NewLogger(clog.Options{Format: clog.FormatJson})
+//	defer lg.Close()
+//	lg.GetLog().Info().Msg("hello")
+type Logger struct {
+	zl zerolog.Logger
+	// out is the writer of zl, used to flush tail buffers.
+	out     zerolog.LevelWriter
+	closers []io.Closer
+}
+
+// defaultLogger is the Logger of the package functions.
+var defaultLogger atomic.Pointer[Logger]
+
+func init() {
+	out := zerolog.MultiLevelWriter(os.Stdout)
+	defaultLogger.Store(&Logger{
+		zl:  zerolog.New(out).With().Timestamp().Caller().Logger(),
+		out: out,
+	})
+}
+
+// Default returns the Logger of the package functions.
+func Default() *Logger {
+	return defaultLogger.Load()
+}
+
+// SetDefault makes l the Logger of the package functions, returning the
+// previous one. Loggers already handed out keep writing to the previous one.
+func SetDefault(l *Logger) *Logger {
+	return defaultLogger.Swap(l)
+}
+
+// NewLogger returns a Logger writing as configured by opt. Unlike
+// NewWithOptions, it leaves the default Logger alone and writes no zerolog
+// globals; the level of opt is still bounded by zerolog.GlobalLevel.
+func NewLogger(opt Options) (*Logger, error) {
+	format := strings.ToLower(opt.Format)
+	files := opt.Files
+	if format == FormatJsonAndFile && len(files) == 0 {
+		files = []FileSink{{ConfigFile: ConfigFile{
+			EnableTimeKey: true,
+			TimeKey:       "200601021504",
+			Path:          "./logs",
+			MaxSize:       "1kb",
+			MaxBackups:    0,
+			MaxAge:        5,     //days
+			Compress:      false, // disabled by default
+		}}}
+	}
+
+	if !opt.Schema.valid() {
+		return nil, fmt.Errorf("unknown schema %q", opt.Schema)
+	}
+	for i, f := range files {
+		if !f.Schema.valid() {
+			return nil, fmt.Errorf("file sink %d: unknown schema %q", i, f.Schema)
+		}
+	}
+
+	l := &Logger{}
+	var writers []io.Writer
+	for i, f := range files {
+		min, max, err := levelRange(f.MinLevel, f.MaxLevel)
+		if err != nil {
+			return nil, fmt.Errorf("file sink %d: %s", i, err)
+		}
+		var file io.Writer = NewLogFile(f.ConfigFile)
+		if f.Failover != nil {
+			file = NewFailoverWriter(file, *f.Failover)
+		}
+		if f.Schema != SchemaClog {
+			file = NewSchemaWriter(file, f.Schema)
+		}
+		sink := NewLevelFilter(file, min, max)
+		writers = append(writers, sink)
+		l.closers = append(l.closers, sink)
+	}
+
+	for _, w := range opt.Sinks {
+		writers = append(writers, w)
+		if c, ok := w.(io.Closer); ok {
+			l.closers = append(l.closers, c)
+		}
+	}
+
+	switch format {
+	case FormatJson, FormatJsonAndFile:
+		var stdout io.Writer = os.Stdout
+		if opt.Schema != SchemaClog {
+			stdout = NewSchemaWriter(stdout, opt.Schema)
+		}
+		writers = append([]io.Writer{stdout}, writers...)
+
+	case FormatLogfmt:
+		writers = append([]io.Writer{NewLogfmtWriter(os.Stdout)}, writers...)
+
+	default: // pretty format
+		writers = append([]io.Writer{NewConsoleWriter(os.Stdout, opt.Console)}, writers...)
+	}
+
+	stamp := timestampHook{unix: format == FormatJson || format == FormatJsonAndFile}
+	multi := zerolog.MultiLevelWriter(writers...)
+	if opt.Dedup != nil {
+		dedup := NewDedupWriter(multi, *opt.Dedup)
+		dedup.stamp = stamp
+		// write the last summaries before the sinks are closed
+		l.closers = append([]io.Closer{dedup}, l.closers...)
+		multi = dedup
+	}
+
+	zl := zerolog.New(multi).Level(optLevel(opt))
+	if opt.Sequence {
+		zl = zl.Hook(seqHook{})
+	}
+	zl = zl.Hook(stamp)
+	zctx := zl.With().Caller()
+	if opt.Process != nil {
+		zctx = zctx.Fields(opt.Process.fields())
+	}
+	l.zl = zctx.Logger()
+	l.out = multi
+	return l, nil
+}
+
+// NewLoggerWriter returns a Logger writing JSON events to w, e.g. to capture
+// the logs of a test.
+func NewLoggerWriter(w io.Writer, level zerolog.Level) *Logger {
+	out, ok := w.(zerolog.LevelWriter)
+	if !ok {
+		out = zerolog.MultiLevelWriter(w)
+	}
+	return &Logger{
+		zl:  zerolog.New(out).Level(level).With().Timestamp().Logger(),
+		out: out,
+	}
+}
+
+func optLevel(opt Options) zerolog.Level {
+	if opt.Debug {
+		return zerolog.DebugLevel
+	}
+	return zerolog.InfoLevel
+}
+
+// GetLog returns a copy of the logger of l.
+func (l *Logger) GetLog() *zerolog.Logger {
+	newLog := new(zerolog.Logger)
+	lg := l.zl.With().Logger()
+	*newLog = lg
+	return newLog
+}
+
+// WithField returns a copy of the logger of l carrying field.
+func (l *Logger) WithField(field map[string]interface{}) *zerolog.Logger {
+	newLog := new(zerolog.Logger)
+	lg := l.zl.With().Fields(field).Logger()
+	*newLog = lg
+	return newLog
+}
+
+// Close shuts l down without a deadline. Events logged after Close may
+// reopen files.
+func (l *Logger) Close() error {
+	return l.Shutdown(context.Background())
+}
+
+// timestampHook adds the event time. JSON events get a number: in the unit of
+// zerolog.TimeFieldFormat if that is a Unix format, in seconds otherwise, so
+// the readers of this package decode it whatever the global format is.
+type timestampHook struct {
+	unix bool
+}
+
+func (h timestampHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	switch {
+	case !h.unix, isUnixFormat(zerolog.TimeFieldFormat):
+		e.Timestamp()
+	default:
+		e.Int64(zerolog.TimestampFieldName, zerolog.TimestampFunc().Unix())
+	}
+}
+
+func isUnixFormat(format string) bool {
+	switch format {
+	case zerolog.TimeFormatUnix, zerolog.TimeFormatUnixMs, zerolog.TimeFormatUnixMicro:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package clog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestLogger_Instances(t *testing.T) {
+	var a, b bytes.Buffer
+	la := NewLoggerWriter(&a, zerolog.InfoLevel)
+	lb := NewLoggerWriter(&b, zerolog.DebugLevel)
+
+	la.WithField(map[string]interface{}{"lib": "a"}).Debug().Msg("skipped")
+	la.GetLog().Info().Msg("from a")
+	lb.GetLog().Debug().Msg("from b")
+
+	if got := decodeLines(t, &a); len(got) != 1 || got[0]["message"] != "from a" {
+		t.Errorf("a = %v", got)
+	}
+	if got := decodeLines(t, &b); len(got) != 1 || got[0]["message"] != "from b" {
+		t.Errorf("b = %v", got)
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+	ctx := peerContext("127.0.0.1:4000", metadata.MD{})
+	b.Reset()
+	lb.UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		GetContextLog(ctx).Info().Msg("handler")
+		return nil, nil
+	})
+	if got := decodeLines(t, &b); len(got) != 3 {
+		t.Errorf("interceptor of b wrote %d lines, want 3", len(got))
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	var a, b bytes.Buffer
+	prev := SetDefault(NewLoggerWriter(&a, zerolog.InfoLevel))
+	defer SetDefault(prev)
+
+	interceptor := UnaryServerInterceptorWithLogger()
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+	call := func() {
+		interceptor(peerContext("127.0.0.1:4000", metadata.MD{}), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	}
+
+	call()
+	GetLog().Info().Msg("a")
+	SetDefault(NewLoggerWriter(&b, zerolog.InfoLevel))
+	call()
+	GetLog().Info().Msg("b")
+
+	if got := decodeLines(t, &a); len(got) != 3 || got[2]["message"] != "a" {
+		t.Errorf("a = %v", got)
+	}
+	if got := decodeLines(t, &b); len(got) != 3 || got[2]["message"] != "b" {
+		t.Errorf("b = %v", got)
+	}
+}
+
+func TestNewLogger_Close(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	lg, err := NewLogger(Options{
+		Format: FormatJson,
+		Files:  []FileSink{{ConfigFile: ConfigFile{Filename: name}, MinLevel: "error"}},
+		Dedup:  &DedupConfig{Burst: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		lg.GetLog().Error().Msg("boom")
+	}
+	if err := lg.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "suppressed 2 similar events") {
+		t.Errorf("file = %q, want the summary written on Close", b)
+	}
+}
+
+func TestNewLogger_TimestampFormat(t *testing.T) {
+	before := zerolog.TimeFieldFormat
+	var buf bytes.Buffer
+	lg, err := NewLogger(Options{Format: FormatJson, Sinks: []io.Writer{&buf}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zerolog.TimeFieldFormat != before {
+		t.Fatalf("TimeFieldFormat = %q, want %q left alone", zerolog.TimeFieldFormat, before)
+	}
+	lg.GetLog().Info().Msg("hello")
+
+	got, err := eventTime(bytes.TrimSpace(buf.Bytes()))
+	if err != nil || time.Since(got) > time.Minute || time.Since(got) < -time.Minute {
+		t.Errorf("time = %v, %v in %s", got, err, buf.Bytes())
+	}
+	if !strings.Contains(buf.String(), `"time":1`) {
+		t.Errorf("event = %s, want a Unix timestamp", buf.Bytes())
+	}
+}
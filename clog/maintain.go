@@ -0,0 +1,68 @@
+package clog
+
+import (
+	"io"
+	"path/filepath"
+)
+
+// MillPlan lists the log files a mill run compresses and removes.
+type MillPlan struct {
+	Compress []LogFile
+	Remove   []LogFile
+}
+
+// PlanMill returns what the file sink configured with cf would compress and
+// remove after its next rotation, without touching any file.
+func PlanMill(cf ConfigFile) (MillPlan, error) {
+	l := NewLogFile(cf)
+	compress, remove, err := l.millPlan()
+	if err != nil {
+		return MillPlan{}, err
+	}
+	return l.millPlanFiles(compress, remove), nil
+}
+
+// RunMill compresses and removes log files the way the file sink configured
+// with cf does after a rotation. It is meant for the log directories of
+// processes that are not running.
+func RunMill(cf ConfigFile) (MillPlan, error) {
+	l := NewLogFile(cf)
+	compress, remove, err := l.millPlan()
+	if err != nil {
+		return MillPlan{}, err
+	}
+	return l.millPlanFiles(compress, remove), l.millExec(compress, remove)
+}
+
+// millPlanFiles converts the result of millPlan.
+func (l *logger) millPlanFiles(compress, remove []logInfo) MillPlan {
+	plan := MillPlan{}
+	for _, f := range compress {
+		plan.Compress = append(plan.Compress, l.logFile(f))
+	}
+	for _, f := range remove {
+		plan.Remove = append(plan.Remove, l.logFile(f))
+	}
+	return plan
+}
+
+func (l *logger) logFile(f logInfo) LogFile {
+	lf, _ := parseLogName(f.Name())
+	lf.Path = filepath.Join(l.dir(), f.Name())
+	lf.Size = f.Size()
+	lf.ModTime = f.ModTime()
+	return lf
+}
+
+// CheckLogFile reads the log file name through, reporting truncated or
+// corrupt gzip and encrypted data. keys may be nil for plain files.
+func CheckLogFile(name string, keys KeyProvider) error {
+	rc, err := OpenLogFile(name, keys)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
@@ -0,0 +1,66 @@
+package clog
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestRunMill(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"202401021500_1704182400.log.gz",
+		"202401021500_1704182460.log",
+		"202401021501_1704182520.log",
+		"202401021502_1704182580.log",
+		"202401021503_latest.log",
+		"notes.txt",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(`{"message":"x"}`+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cf := ConfigFile{EnableTimeKey: true, TimeKey: "200601021504", Path: dir, MaxBackups: 2, Compress: true}
+	plan, err := PlanMill(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := baseNames(plan.Remove); len(got) != 2 || got[0] != "202401021500_1704182400.log.gz" || got[1] != "202401021500_1704182460.log" {
+		t.Errorf("remove = %v", got)
+	}
+	if got := baseNames(plan.Compress); len(got) != 2 || got[0] != "202401021501_1704182520.log" || got[1] != "202401021502_1704182580.log" {
+		t.Errorf("compress = %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, names[0])); err != nil {
+		t.Error("dry run removed a file")
+	}
+
+	if _, err := RunMill(cf); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ListLogFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"202401021501_1704182520.log.gz", "202401021502_1704182580.log.gz", "202401021503_latest.log"}
+	if got := baseNames(files); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("after mill = %v, want %v", got, want)
+	}
+	for _, f := range files {
+		if err := CheckLogFile(f.Path, nil); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func baseNames(files []LogFile) []string {
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f.Path))
+	}
+	sort.Strings(names)
+	return names
+}
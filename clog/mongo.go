@@ -0,0 +1,155 @@
+package clog
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	// DatabaseField key.
+	DatabaseField = "db"
+	// CollectionField key.
+	CollectionField = "collection"
+	// CommandField key of the command name.
+	CommandField = "command"
+	// CommandDocField key of the command document.
+	CommandDocField = "commandDoc"
+	// MongoMessageDefault of logging MongoDB commands.
+	MongoMessageDefault = "mongo"
+)
+
+// MongoMonitorConfig configures the MongoDB command monitors.
+type MongoMonitorConfig struct {
+	// SlowThreshold logs the commands slower than it at warn level. Zero
+	// disables it. Failed commands are logged at error level, the others
+	// at debug level.
+	SlowThreshold time.Duration
+
+	// LogCommand logs the command documents, up to MaxCommandSize bytes,
+	// with RedactKeys redacted. Authentication commands are never logged.
+	LogCommand bool
+
+	// MaxCommandSize of the logged command documents. The default is 4KB.
+	MaxCommandSize int
+}
+
+// NewMongoMonitor returns a command monitor for mongo-driver v1, logging
+// every command with the logger and trace ID of the operation context.
+//
+//	opts := options.Client().ApplyURI(uri).SetMonitor(clog.NewMongoMonitor(clog.MongoMonitorConfig{
+//		SlowThreshold: 100 * time.Millisecond,
+//	}))
+func NewMongoMonitor(cf MongoMonitorConfig) *event.CommandMonitor {
+	m := newMongoMonitor(cf)
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, evt *event.CommandStartedEvent) {
+			var doc []byte
+			if m.logCommand(evt.CommandName) {
+				doc, _ = bson.MarshalExtJSON(evt.Command, false, false)
+			}
+			collection, _ := evt.Command.Lookup(evt.CommandName).StringValueOK()
+			if evt.CommandName == "getMore" {
+				collection, _ = evt.Command.Lookup("collection").StringValueOK()
+			}
+			m.start(evt.ConnectionID, evt.RequestID, collection, doc)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			m.finish(ctx, evt.ConnectionID, evt.RequestID, evt.CommandName, evt.DatabaseName, evt.Duration, nil)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			m.finish(ctx, evt.ConnectionID, evt.RequestID, evt.CommandName, evt.DatabaseName, evt.Duration, mongoFailure(evt.Failure))
+		},
+	}
+}
+
+// mongoFailure is the failure of a mongo-driver v1 command.
+type mongoFailure string
+
+func (f mongoFailure) Error() string { return string(f) }
+
+// mongoRequest identifies a command between its start and finish events.
+type mongoRequest struct {
+	conn string
+	id   int64
+}
+
+// mongoCommand is what only the start event of a command knows.
+type mongoCommand struct {
+	collection string
+	doc        []byte
+}
+
+// mongoMonitor logs the commands of both driver versions.
+type mongoMonitor struct {
+	cf MongoMonitorConfig
+
+	mu      sync.Mutex
+	started map[mongoRequest]mongoCommand
+}
+
+// mongoAuthCommands carry credentials in their documents.
+var mongoAuthCommands = map[string]bool{
+	"authenticate": true, "saslStart": true, "saslContinue": true, "getnonce": true,
+	"createUser": true, "updateUser": true, "copydbgetnonce": true, "copydbsaslstart": true, "copydb": true,
+}
+
+func newMongoMonitor(cf MongoMonitorConfig) *mongoMonitor {
+	if cf.MaxCommandSize <= 0 {
+		cf.MaxCommandSize = 4096
+	}
+	return &mongoMonitor{cf: cf, started: map[mongoRequest]mongoCommand{}}
+}
+
+// logCommand reports whether the document of the command name is logged.
+func (m *mongoMonitor) logCommand(name string) bool {
+	return m.cf.LogCommand && !mongoAuthCommands[name]
+}
+
+func (m *mongoMonitor) start(conn string, id int64, collection string, doc []byte) {
+	if doc != nil {
+		doc = RedactJSON(doc)
+		if len(doc) > m.cf.MaxCommandSize {
+			doc = doc[:m.cf.MaxCommandSize]
+		}
+	}
+
+	m.mu.Lock()
+	m.started[mongoRequest{conn, id}] = mongoCommand{collection, doc}
+	m.mu.Unlock()
+}
+
+func (m *mongoMonitor) finish(ctx context.Context, conn string, id int64, name, db string, dur time.Duration, failure error) {
+	m.mu.Lock()
+	cmd := m.started[mongoRequest{conn, id}]
+	delete(m.started, mongoRequest{conn, id})
+	m.mu.Unlock()
+
+	log := contextLog(ctx)
+	var e *zerolog.Event
+	switch {
+	case failure != nil:
+		e = log.Error().Err(failure)
+	case m.cf.SlowThreshold > 0 && dur >= m.cf.SlowThreshold:
+		e = log.Warn()
+	default:
+		e = log.Debug()
+	}
+	if !e.Enabled() {
+		return
+	}
+
+	e = withTraceID(ctx, e).Str(CommandField, name).Str(DatabaseField, db)
+	if cmd.collection != "" {
+		e = e.Str(CollectionField, cmd.collection)
+	}
+	e = e.Dur(DurationField, dur)
+	if cmd.doc != nil {
+		e = logBody(e, CommandDocField, cmd.doc)
+	}
+	e.Msg(MongoMessageDefault)
+}
@@ -0,0 +1,73 @@
+package clog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	bsonv2 "go.mongodb.org/mongo-driver/v2/bson"
+	eventv2 "go.mongodb.org/mongo-driver/v2/event"
+)
+
+func TestMongoMonitor(t *testing.T) {
+	buf := withTestLogger(t)
+	mon := NewMongoMonitor(MongoMonitorConfig{SlowThreshold: 100 * time.Millisecond, LogCommand: true})
+	ctx := context.WithValue(context.Background(), CTraceIDKey, "abc")
+
+	cmd, _ := bson.Marshal(bson.D{{Key: "find", Value: "wallets"}, {Key: "filter", Value: bson.D{{Key: "pin", Value: "1234"}}}})
+	mon.Started(ctx, &event.CommandStartedEvent{Command: cmd, CommandName: "find", DatabaseName: "bank", ConnectionID: "c1", RequestID: 1})
+	mon.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{
+		CommandName: "find", DatabaseName: "bank", ConnectionID: "c1", RequestID: 1, Duration: 150 * time.Millisecond,
+	}})
+
+	auth, _ := bson.Marshal(bson.D{{Key: "saslStart", Value: 1}, {Key: "payload", Value: "secret"}})
+	mon.Started(ctx, &event.CommandStartedEvent{Command: auth, CommandName: "saslStart", DatabaseName: "admin", ConnectionID: "c1", RequestID: 2})
+	mon.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: event.CommandFinishedEvent{
+		CommandName: "saslStart", DatabaseName: "admin", ConnectionID: "c1", RequestID: 2,
+	}, Failure: "auth failed"})
+
+	lines := decodeLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	slow := lines[0]
+	if slow["level"] != "warn" || slow[CollectionField] != "wallets" || slow[DatabaseField] != "bank" || slow[TraceIDField] != "abc" {
+		t.Errorf("slow line = %v", slow)
+	}
+	if doc, _ := slow[CommandDocField].(map[string]interface{}); doc == nil || strings.Contains(buf.String(), "1234") {
+		t.Errorf("commandDoc = %v, want the document with the pin redacted", slow[CommandDocField])
+	}
+	failed := lines[1]
+	if failed["level"] != "error" || failed["error"] != "auth failed" || failed[CommandDocField] != nil {
+		t.Errorf("failed line = %v", failed)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Error("authentication payload logged")
+	}
+}
+
+func TestMongoMonitorV2(t *testing.T) {
+	buf := withTestLogger(t)
+	mon := NewMongoMonitorV2(MongoMonitorConfig{})
+	log := WithField(map[string]interface{}{TraceIDField: "abc"})
+	ctx := context.WithValue(context.Background(), CLoggerKey, log)
+
+	cmd, _ := bsonv2.Marshal(bsonv2.D{{Key: "getMore", Value: int64(7)}, {Key: "collection", Value: "ledger"}})
+	mon.Started(ctx, &eventv2.CommandStartedEvent{Command: cmd, CommandName: "getMore", DatabaseName: "bank", ConnectionID: "c1", RequestID: 1})
+	mon.Failed(ctx, &eventv2.CommandFailedEvent{CommandFinishedEvent: eventv2.CommandFinishedEvent{
+		CommandName: "getMore", DatabaseName: "bank", ConnectionID: "c1", RequestID: 1,
+	}, Failure: errors.New("cursor not found")})
+
+	lines := decodeLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+	m := lines[0]
+	if m[CollectionField] != "ledger" || m[TraceIDField] != "abc" || m["error"] != "cursor not found" {
+		t.Errorf("line = %v", m)
+	}
+}
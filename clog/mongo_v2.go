@@ -0,0 +1,37 @@
+package clog
+
+import (
+	"context"
+
+	bsonv2 "go.mongodb.org/mongo-driver/v2/bson"
+	eventv2 "go.mongodb.org/mongo-driver/v2/event"
+)
+
+// NewMongoMonitorV2 returns a command monitor for mongo-driver v2, logging
+// every command with the logger and trace ID of the operation context.
+//
+//	opts := options.Client().ApplyURI(uri).SetMonitor(clog.NewMongoMonitorV2(clog.MongoMonitorConfig{
+//		SlowThreshold: 100 * time.Millisecond,
+//	}))
+func NewMongoMonitorV2(cf MongoMonitorConfig) *eventv2.CommandMonitor {
+	m := newMongoMonitor(cf)
+	return &eventv2.CommandMonitor{
+		Started: func(_ context.Context, evt *eventv2.CommandStartedEvent) {
+			var doc []byte
+			if m.logCommand(evt.CommandName) {
+				doc, _ = bsonv2.MarshalExtJSON(evt.Command, false, false)
+			}
+			collection, _ := evt.Command.Lookup(evt.CommandName).StringValueOK()
+			if evt.CommandName == "getMore" {
+				collection, _ = evt.Command.Lookup("collection").StringValueOK()
+			}
+			m.start(evt.ConnectionID, evt.RequestID, collection, doc)
+		},
+		Succeeded: func(ctx context.Context, evt *eventv2.CommandSucceededEvent) {
+			m.finish(ctx, evt.ConnectionID, evt.RequestID, evt.CommandName, evt.DatabaseName, evt.Duration, nil)
+		},
+		Failed: func(ctx context.Context, evt *eventv2.CommandFailedEvent) {
+			m.finish(ctx, evt.ConnectionID, evt.RequestID, evt.CommandName, evt.DatabaseName, evt.Duration, evt.Failure)
+		},
+	}
+}
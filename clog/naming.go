@@ -0,0 +1,159 @@
+package clog
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The file sink names its files:
+//
+//	<time key>_latest.log          current file of a time key
+//	<time key>_<unix>.log[.gz]     backup rotated at <unix>
+//	<name>.log                     current file of a Filename sink
+//	<name>_<unix>.log[.gz]         backup of a Filename sink
+const (
+	latestSuffix = "_latest"
+	logSuffix    = ".log"
+)
+
+// LogFile is a file of a log directory written by the file sink.
+type LogFile struct {
+	// Path of the file.
+	Path string
+
+	// Key is the time key, or the name of a Filename sink.
+	Key string
+
+	// Latest is true for the file currently written for Key.
+	Latest bool
+
+	// Rotated is the time encoded in the name of a backup file.
+	Rotated time.Time
+
+	// Compressed is true for gzipped backups.
+	Compressed bool
+
+	Size    int64
+	ModTime time.Time
+}
+
+// Backup reports whether f is a rotated backup.
+func (f LogFile) Backup() bool {
+	return !f.Rotated.IsZero()
+}
+
+// KeyTime parses the time key of f with layout in loc.
+func (f LogFile) KeyTime(layout string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation(layout, f.Key, loc)
+}
+
+// KeyRange returns the period covered by the time key of f.
+func (f LogFile) KeyRange(layout string, loc *time.Location) (start, end time.Time, err error) {
+	start, err = f.KeyTime(layout, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, start.Add(TimeKeySpan(layout)), nil
+}
+
+// TimeKeySpan returns the period covered by one time key of layout, taken
+// from the smallest unit of the layout.
+func TimeKeySpan(layout string) time.Duration {
+	switch {
+	case strings.Contains(layout, "05"):
+		return time.Second
+	case strings.Contains(layout, "04"):
+		return time.Minute
+	case strings.Contains(layout, "15"):
+		return time.Hour
+	case strings.Contains(layout, "02"):
+		return 24 * time.Hour
+	case strings.Contains(layout, "01"):
+		return 31 * 24 * time.Hour
+	default:
+		return 366 * 24 * time.Hour
+	}
+}
+
+// ListLogFiles returns the log files of dir, ordered by Key with the backups
+// of a key by rotation time, followed by its latest file.
+func ListLogFiles(dir string) ([]LogFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []LogFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		f, ok := parseLogName(e.Name())
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		f.Path = filepath.Join(dir, e.Name())
+		f.Size = info.Size()
+		f.ModTime = info.ModTime()
+		files = append(files, f)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		if a.Backup() != b.Backup() {
+			return a.Backup()
+		}
+		return a.Rotated.Before(b.Rotated)
+	})
+	return files, nil
+}
+
+// parseLogName parses a base file name written by the file sink.
+func parseLogName(name string) (LogFile, bool) {
+	f := LogFile{}
+	base := name
+	if strings.HasSuffix(base, compressSuffix) {
+		f.Compressed = true
+		base = strings.TrimSuffix(base, compressSuffix)
+	}
+	if !strings.HasSuffix(base, logSuffix) {
+		return LogFile{}, false
+	}
+	base = strings.TrimSuffix(base, logSuffix)
+	if base == "" {
+		return LogFile{}, false
+	}
+
+	if strings.HasSuffix(base, latestSuffix) {
+		f.Key = strings.TrimSuffix(base, latestSuffix)
+		f.Latest = !f.Compressed
+		return f, f.Key != ""
+	}
+
+	if i := strings.LastIndexByte(base, '_'); i > 0 {
+		if sec, err := strconv.ParseInt(base[i+1:], 10, 64); err == nil {
+			f.Key = base[:i]
+			f.Rotated = time.Unix(sec, 0)
+			return f, true
+		}
+	}
+
+	// the current file of a Filename sink
+	if f.Compressed {
+		return LogFile{}, false
+	}
+	f.Key = base
+	f.Latest = true
+	return f, true
+}
@@ -0,0 +1,58 @@
+package clog
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseLogName(t *testing.T) {
+	cases := []struct {
+		name    string
+		ok      bool
+		key     string
+		latest  bool
+		rotated int64
+		gz      bool
+	}{
+		{"202401021504_latest.log", true, "202401021504", true, 0, false},
+		{"202401021504_1704186000.log", true, "202401021504", false, 1704186000, false},
+		{"202401021504_1704186000.log.gz", true, "202401021504", false, 1704186000, true},
+		{"app.log", true, "app", true, 0, false},
+		{"app_1704186000.log.gz", true, "app", false, 1704186000, true},
+		{"my_app.log", true, "my_app", true, 0, false},
+		{"notes.txt", false, "", false, 0, false},
+		{".log", false, "", false, 0, false},
+		{"app.log.gz", false, "", false, 0, false},
+	}
+	for _, c := range cases {
+		f, ok := parseLogName(c.name)
+		if ok != c.ok {
+			t.Errorf("%s: ok = %v, want %v", c.name, ok, c.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if f.Key != c.key || f.Latest != c.latest || f.Compressed != c.gz {
+			t.Errorf("%s: got %+v", c.name, f)
+		}
+		if c.rotated != 0 && f.Rotated.Unix() != c.rotated {
+			t.Errorf("%s: rotated = %v, want %d", c.name, f.Rotated.Unix(), c.rotated)
+		}
+	}
+}
+
+func TestBackupName_KeepsTimeKey(t *testing.T) {
+	fakeClock(t)
+	l := NewLogFile(ConfigFile{EnableTimeKey: true, TimeKey: "200601021504", Path: "logs"})
+	l.lastLogTime = time.Date(2024, 1, 2, 8, 4, 0, 0, time.UTC)
+
+	f, ok := parseLogName(filepath.Base(l.backupName()))
+	if !ok {
+		t.Fatal("backup name not parsed")
+	}
+	if f.Key != "202401021504" || !f.Backup() {
+		t.Errorf("backup = %+v, want key 202401021504", f)
+	}
+}
@@ -0,0 +1,75 @@
+package clog
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultDialTimeout bounds dialing and writing to a network collector.
+const defaultDialTimeout = 5 * time.Second
+
+// netConn is a connection to a log collector. It is dialed on first use and
+// redialed once after a failed write, so a restarted collector is picked up
+// again without losing the event.
+type netConn struct {
+	network string
+	addr    string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newNetConn(network, addr string, timeout time.Duration) *netConn {
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+	return &netConn{network: network, addr: addr, timeout: timeout}
+}
+
+// write writes frames in order, each as a single write so datagram transports
+// send one packet per frame.
+func (c *netConn) write(frames ...[]byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.writeFrames(frames)
+	if err == nil {
+		return nil
+	}
+	c.closeConn()
+	return c.writeFrames(frames)
+}
+
+func (c *netConn) writeFrames(frames [][]byte) error {
+	if c.conn == nil {
+		conn, err := net.DialTimeout(c.network, c.addr, c.timeout)
+		if err != nil {
+			return err
+		}
+		c.conn = conn
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	for _, frame := range frames {
+		if _, err := c.conn.Write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *netConn) closeConn() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *netConn) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeConn()
+}
@@ -0,0 +1,121 @@
+package clog
+
+import (
+	"os"
+	"path"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	// HostField key.
+	HostField = "host"
+	// PIDField key.
+	PIDField = "pid"
+	// AppField key of the application name, apart from the gRPC ServiceField.
+	AppField = "app"
+	// VersionField key.
+	VersionField = "version"
+	// RevisionField key of the VCS revision.
+	RevisionField = "revision"
+	// EnvField key of the deployment environment.
+	EnvField = "env"
+	// PodField key of the Kubernetes pod.
+	PodField = "pod"
+	// NamespaceField key of the Kubernetes namespace.
+	NamespaceField = "namespace"
+	// SeqField key of the per-process sequence number.
+	SeqField = "seq"
+)
+
+// ProcessInfo describes the process for every log line. Empty fields are not
+// logged.
+type ProcessInfo struct {
+	Host      string
+	PID       int
+	App       string
+	Version   string
+	Revision  string
+	Env       string
+	Pod       string
+	Namespace string
+}
+
+// DetectProcessInfo returns the ProcessInfo of the running process. The
+// application name, version and revision come from the build info, unless
+// APP_NAME or APP_VERSION are set. The environment comes from APP_ENV, the
+// pod and namespace from POD_NAME and POD_NAMESPACE, as set by the
+// Kubernetes downward API:
+//
+//	env:
+//	  - name: POD_NAME
+//	    valueFrom: {fieldRef: {fieldPath: metadata.name}}
+//	  - name: POD_NAMESPACE
+//	    valueFrom: {fieldRef: {fieldPath: metadata.namespace}}
+func DetectProcessInfo() ProcessInfo {
+	info := ProcessInfo{
+		PID:       os.Getpid(),
+		App:       os.Getenv("APP_NAME"),
+		Version:   os.Getenv("APP_VERSION"),
+		Env:       os.Getenv("APP_ENV"),
+		Pod:       os.Getenv("POD_NAME"),
+		Namespace: os.Getenv("POD_NAMESPACE"),
+	}
+	info.Host, _ = os.Hostname()
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.App == "" && bi.Path != "" {
+			info.App = path.Base(bi.Path)
+		}
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		dirty := false
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Revision = s.Value
+			case "vcs.modified":
+				dirty = s.Value == "true"
+			}
+		}
+		if dirty && info.Revision != "" {
+			info.Revision += "-dirty"
+		}
+	}
+	return info
+}
+
+// fields returns the non-empty fields of info.
+func (info ProcessInfo) fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	for k, v := range map[string]string{
+		HostField:      info.Host,
+		AppField:       info.App,
+		VersionField:   info.Version,
+		RevisionField:  info.Revision,
+		EnvField:       info.Env,
+		PodField:       info.Pod,
+		NamespaceField: info.Namespace,
+	} {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	if info.PID != 0 {
+		fields[PIDField] = info.PID
+	}
+	return fields
+}
+
+// processSeq numbers the events of every Logger of the process.
+var processSeq uint64
+
+// seqHook adds the next per-process sequence number to every event.
+type seqHook struct{}
+
+func (seqHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	e.Uint64(SeqField, atomic.AddUint64(&processSeq, 1))
+}
@@ -0,0 +1,57 @@
+package clog
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestDetectProcessInfo(t *testing.T) {
+	t.Setenv("APP_NAME", "wallet")
+	t.Setenv("APP_ENV", "staging")
+	t.Setenv("POD_NAME", "wallet-7d9f-abcde")
+	t.Setenv("POD_NAMESPACE", "payments")
+
+	info := DetectProcessInfo()
+	if info.App != "wallet" || info.Env != "staging" || info.Pod != "wallet-7d9f-abcde" || info.Namespace != "payments" {
+		t.Errorf("info = %+v", info)
+	}
+	if info.PID != os.Getpid() || info.Host == "" {
+		t.Errorf("info = %+v, want the pid and hostname", info)
+	}
+}
+
+func TestNewLogger_ProcessAndSequence(t *testing.T) {
+	var buf bytes.Buffer
+	lg, err := NewLogger(Options{
+		Format:   FormatJson,
+		Sinks:    []io.Writer{&buf},
+		Process:  &ProcessInfo{Host: "node-1", PID: 42, App: "wallet", Version: "v1.2.3"},
+		Sequence: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg.GetLog().Info().Msg("a")
+	lg.WithField(map[string]interface{}{ServiceField: "pkg.Wallet"}).Info().Msg("b")
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	for _, m := range lines {
+		if m[HostField] != "node-1" || m[PIDField] != 42.0 || m[AppField] != "wallet" || m[VersionField] != "v1.2.3" {
+			t.Errorf("line = %v", m)
+		}
+		if _, ok := m[EnvField]; ok {
+			t.Errorf("line = %v, want empty fields left out", m)
+		}
+	}
+	if lines[1][ServiceField] != "pkg.Wallet" {
+		t.Errorf("service = %v", lines[1][ServiceField])
+	}
+	if a, b := lines[0][SeqField].(float64), lines[1][SeqField].(float64); b != a+1 {
+		t.Errorf("seq = %v, %v, want consecutive numbers", a, b)
+	}
+}
@@ -0,0 +1,122 @@
+package clog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Recovery lists the files left behind by a crashed process that a recovery
+// finalized and removed.
+type Recovery struct {
+	// Finalized are the latest files of past time keys, moved aside as
+	// backups. Path is the name of the backup.
+	Finalized []LogFile
+
+	// Removed are the partial gzips of interrupted compressions, and the
+	// empty latest files of past time keys.
+	Removed []LogFile
+}
+
+// RecoverLogFiles recovers the log directory of the file sink configured
+// with cf, like the sink does when it starts, and then compresses and
+// removes its files like RunMill. Every latest file is finalized, so it is
+// meant for the log directories of processes that are not running.
+func RecoverLogFiles(cf ConfigFile) (Recovery, MillPlan, error) {
+	l := NewLogFile(cf)
+	rec, err := l.recoverFiles("")
+	if err != nil {
+		return rec, MillPlan{}, err
+	}
+	plan, err := RunMill(cf)
+	return rec, plan, err
+}
+
+// recoverFiles finalizes the latest files of the past time keys of l into
+// backups, so the mill compresses and removes them, and removes the gzips
+// whose compression was interrupted. current is the latest file in use; the
+// files of other sinks sharing the directory, and those of the current or
+// later keys, are left alone.
+//
+// A gzip is partial when its source is still there: the source is only
+// removed once the gzip is complete. Corrupt gzips without a source are the
+// only copy of their logs and are kept, see CheckLogFile.
+func (l *logger) recoverFiles(current string) (rec Recovery, err error) {
+	files, err := ListLogFiles(l.dir())
+	if err != nil {
+		return rec, fmt.Errorf("can't read log file directory: %s", err)
+	}
+
+	var prefix string
+	if !l.EnableTimeKey {
+		prefix, _ = l.prefixAndExt()
+	}
+	names := make(map[string]bool, len(files))
+	for _, f := range files {
+		names[f.Path] = true
+	}
+	currentTime, currentSeq, hasCurrent := time.Time{}, 0, false
+	if lf, ok := parseLogName(filepath.Base(current)); ok && current != "" {
+		currentTime, currentSeq, hasCurrent = l.parseKey(lf.Key)
+	}
+
+	for _, f := range files {
+		// other sinks may share the directory
+		if !l.ownsKey(f.Key, prefix) {
+			continue
+		}
+
+		var errRecover error
+		switch {
+		case f.Compressed:
+			if !names[strings.TrimSuffix(f.Path, compressSuffix)] || CheckLogFile(f.Path, l.KeyProvider) == nil {
+				continue
+			}
+			if errRecover = os.Remove(f.Path); errRecover == nil {
+				rec.Removed = append(rec.Removed, f)
+			}
+
+		case f.Latest && l.EnableTimeKey && (!hasCurrent || l.earlierKey(f.Key, currentTime, currentSeq)):
+			if f.Size == 0 {
+				if errRecover = os.Remove(f.Path); errRecover == nil {
+					rec.Removed = append(rec.Removed, f)
+				}
+				continue
+			}
+			backup := l.finalBackupName(f)
+			if errRecover = os.Rename(f.Path, backup); errRecover == nil {
+				f.Path = backup
+				f.Latest = false
+				f.Rotated = time.Unix(f.ModTime.Unix(), 0)
+				rec.Finalized = append(rec.Finalized, f)
+			}
+		}
+		if err == nil && errRecover != nil {
+			err = errRecover
+		}
+	}
+	return rec, err
+}
+
+// earlierKey reports whether key is a key of l before the key of time t and
+// sequence number seq.
+func (l *logger) earlierKey(key string, t time.Time, seq int) bool {
+	kt, kseq, ok := l.parseKey(key)
+	if !ok {
+		return false
+	}
+	return kt.Before(t) || kt.Equal(t) && kseq < seq
+}
+
+// finalBackupName returns a free backup name for the latest file f, rotated
+// when it was last written.
+func (l *logger) finalBackupName(f LogFile) string {
+	for sec := f.ModTime.Unix(); ; sec++ {
+		name := filepath.Join(l.dir(), fmt.Sprintf("%s_%d%s", f.Key, sec, logSuffix))
+		if _, err := osStat(name); os.IsNotExist(err) {
+			return name
+		}
+	}
+}
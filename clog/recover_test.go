@@ -0,0 +1,91 @@
+package clog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogFile_Recover(t *testing.T) {
+	fakeClock(t)
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)
+	write("2024010207_latest.log", `{"message":"abandoned"}`+"\n")
+	os.Chtimes(filepath.Join(dir, "2024010207_latest.log"), past, past)
+	write("2024010206_latest.log", "")
+	// compression of a backup interrupted halfway
+	write("2024010205_1704171600.log", `{"message":"pending"}`+"\n")
+	write("2024010205_1704171600.log.gz", "\x1f\x8b\x08")
+	// the only copy of its logs
+	write("2024010204_1704168000.log.gz", "\x1f\x8b\x08")
+
+	l := NewLogFile(ConfigFile{EnableTimeKey: true, TimeKey: "2006010215", Path: dir, TImeZone: time.UTC, Compress: true})
+	writeAt(t, l, time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC))
+	if err := l.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"2024010204_1704168000.log.gz",
+		"2024010205_1704171600.log.gz",
+		"2024010207_1704182400.log.gz",
+		"2024010209_latest.log",
+	}
+	got := dirNames(t, dir)
+	if len(got) != len(want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("files = %v, want %v", got, want)
+		}
+	}
+	for _, name := range want[1:3] {
+		if err := CheckLogFile(filepath.Join(dir, name), nil); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestLogFile_RecoverSharedDirectory(t *testing.T) {
+	fakeClock(t)
+	dir := t.TempDir()
+	sink := func(service string) *logger {
+		return NewLogFile(ConfigFile{Path: dir, FilenameTemplate: "{service}-{time}", Service: service, TImeZone: time.UTC})
+	}
+	day := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	// a stale file of b, finalized by b only
+	stale := filepath.Join(dir, "b-20240101_latest.log")
+	if err := os.WriteFile(stale, []byte(`{"message":"stale"}`+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := sink("a"), sink("b")
+	defer a.Close()
+	defer b.Close()
+	writeAt(t, a, day)
+	writeAt(t, b, day)
+	writeAt(t, a, day)
+
+	names := dirNames(t, dir)
+	want := []string{"a-20240102_latest.log", "b-20240101_", "b-20240102_latest.log"}
+	if len(names) != len(want) {
+		t.Fatalf("files = %v", names)
+	}
+	for i, w := range want {
+		if names[i][:len(w)] != w {
+			t.Fatalf("files = %v", names)
+		}
+	}
+	if got := readLogFile(t, filepath.Join(dir, "a-20240102_latest.log"), nil); got != `{"time":"2024-01-02T09:00:00Z","message":"x"}`+"\n"+`{"time":"2024-01-02T09:00:00Z","message":"x"}`+"\n" {
+		t.Errorf("a = %q", got)
+	}
+}
@@ -0,0 +1,131 @@
+package clog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// RedactKeys are the metadata keys, query parameters and JSON body fields
+	// whose values are never logged. Matching ignores case, and '-' and '_'
+	// are the same.
+	RedactKeys = []string{
+		"authorization", "cookie", "set-cookie", "x-api-key", "api-key", "apikey",
+		"password", "passwd", "secret", "client-secret", "token", "access-token",
+		"refresh-token", "pin", "otp", "cvv", "card-number",
+	}
+	// RedactedValue replaces the values of RedactKeys.
+	RedactedValue = "[REDACTED]"
+)
+
+// redacted reports whether the value of key must not be logged.
+func redacted(key string) bool {
+	key = strings.ReplaceAll(strings.ToLower(key), "_", "-")
+	for _, k := range RedactKeys {
+		if strings.ReplaceAll(strings.ToLower(k), "_", "-") == key {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactURL returns u with the values of redacted query parameters and any
+// password replaced.
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	r := *u
+	if _, ok := r.User.Password(); ok {
+		r.User = url.UserPassword(r.User.Username(), RedactedValue)
+	}
+	if r.RawQuery != "" {
+		q := r.Query()
+		changed := false
+		for k, vs := range q {
+			if redacted(k) {
+				for i := range vs {
+					vs[i] = RedactedValue
+				}
+				changed = true
+			}
+		}
+		if changed {
+			r.RawQuery = q.Encode()
+		}
+	}
+	return r.String()
+}
+
+// RedactJSON returns the JSON document b with the values of redacted fields
+// replaced, at any depth. b is returned unchanged when nothing is redacted or
+// it is not JSON.
+func RedactJSON(b []byte) []byte {
+	var doc interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return b
+	}
+	if !redactValue(doc) {
+		return b
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return b
+	}
+	return out
+}
+
+// redactValue redacts v in place, reporting whether anything was redacted.
+func redactValue(v interface{}) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if redacted(k) {
+				v[k] = RedactedValue
+				changed = true
+			} else if redactValue(field) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if redactValue(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+var (
+	// jsonPair matches a string field of a possibly truncated JSON document.
+	jsonPair = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"(\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]*)`)
+	// formPair matches a field of a form body.
+	formPair = regexp.MustCompile(`(^|&)([^=&]+)=([^&]*)`)
+)
+
+// RedactText returns the body b, which may be truncated or not JSON at all,
+// with the values of redacted JSON and form fields replaced.
+func RedactText(b []byte) []byte {
+	b = jsonPair.ReplaceAllFunc(b, func(m []byte) []byte {
+		sub := jsonPair.FindSubmatch(m)
+		if !redacted(string(sub[1])) {
+			return m
+		}
+		return []byte(`"` + string(sub[1]) + `"` + string(sub[2]) + `"` + RedactedValue + `"`)
+	})
+	return formPair.ReplaceAllFunc(b, func(m []byte) []byte {
+		sub := formPair.FindSubmatch(m)
+		key, err := url.QueryUnescape(string(sub[2]))
+		if err != nil || !redacted(key) {
+			return m
+		}
+		return []byte(string(sub[1]) + string(sub[2]) + "=" + url.QueryEscape(RedactedValue))
+	})
+}
@@ -0,0 +1,322 @@
+package clog
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rs/zerolog"
+)
+
+// ensure we always implement zerolog.LevelWriter
+var _ zerolog.LevelWriter = (*RingBuffer)(nil)
+
+// RingBufferConfig configures a RingBuffer.
+type RingBufferConfig struct {
+	// Size is the number of events kept, default 1000.
+	Size int
+
+	// Auth authorizes the requests of the handlers from their token: the
+	// bearer token of the Authorization header, or the token query parameter
+	// of EventSource clients, which cannot set headers. Every request is
+	// refused when Auth is nil.
+	Auth func(token string) bool
+
+	// KeepAlive is the interval of the comments keeping streams open through
+	// proxies, default 15s.
+	KeepAlive time.Duration
+}
+
+// RingBuffer is a sink keeping the last events in memory, served by Handler
+// and FiberHandler for a live view of one instance.
+//
+//	ring := clog.NewRingBuffer(clog.RingBufferConfig{Auth: checkToken})
+//	clog.NewWithOptions(clog.Options{Format: clog.FormatJson, Sinks: []io.Writer{ring}})
+//	app.Get("/debug/logs", ring.FiberHandler())
+//
+// The handlers take the query parameters:
+//
+//	level     minimum level of the events, e.g. warn
+//	traceID   trace ID of the events
+//	limit     number of buffered events returned, default all
+//	stream    streams the events as they are written over Server-Sent Events,
+//	          also selected by an Accept header of text/event-stream
+type RingBuffer struct {
+	cf RingBufferConfig
+
+	mu     sync.Mutex
+	events []ringEvent
+	next   int
+	full   bool
+	subs   map[chan ringEvent]struct{}
+	done   chan struct{}
+	closed bool
+}
+
+// ringEvent is an event of a RingBuffer.
+type ringEvent struct {
+	level   zerolog.Level
+	traceID string
+	data    []byte
+}
+
+// ringQuery filters the events served by a RingBuffer.
+type ringQuery struct {
+	min     zerolog.Level
+	traceID string
+	limit   int
+	stream  bool
+}
+
+// ringSubscriberSize is the number of events a stream may fall behind before
+// it misses events.
+const ringSubscriberSize = 256
+
+func NewRingBuffer(cf RingBufferConfig) *RingBuffer {
+	if cf.Size <= 0 {
+		cf.Size = 1000
+	}
+	if cf.KeepAlive <= 0 {
+		cf.KeepAlive = 15 * time.Second
+	}
+	return &RingBuffer{
+		cf:     cf,
+		events: make([]ringEvent, cf.Size),
+		subs:   make(map[chan ringEvent]struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Write implements io.Writer, taking the level from the event.
+func (r *RingBuffer) Write(p []byte) (n int, err error) {
+	return r.WriteLevel(eventLevel(p), p)
+}
+
+// WriteLevel implements zerolog.LevelWriter.
+func (r *RingBuffer) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
+	evt := ringEvent{level: level, data: append([]byte(nil), bytes.TrimRight(p, "\n")...)}
+	if raw, found, ok := scanField(evt.data, TraceIDField); ok && found {
+		evt.traceID, _ = unquoteField(raw)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[r.next] = evt
+	r.next = (r.next + 1) % len(r.events)
+	r.full = r.full || r.next == 0
+	for ch := range r.subs {
+		// slow streams miss events rather than block the logger
+		select {
+		case ch <- evt:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer, ending the streams.
+func (r *RingBuffer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.closed = true
+		close(r.done)
+	}
+	return nil
+}
+
+// snapshot returns the buffered events matching q, oldest first.
+func (r *RingBuffer) snapshot(q ringQuery) [][]byte {
+	var out [][]byte
+	start := 0
+	if r.full {
+		start = r.next
+	}
+	for i := 0; i < len(r.events); i++ {
+		evt := r.events[(start+i)%len(r.events)]
+		if evt.data == nil {
+			break
+		}
+		if q.match(evt) {
+			out = append(out, evt.data)
+		}
+	}
+	if q.limit > 0 && len(out) > q.limit {
+		out = out[len(out)-q.limit:]
+	}
+	return out
+}
+
+// follow returns the buffered events matching q and subscribes to the next
+// events, until cancel is called.
+func (r *RingBuffer) follow(q ringQuery) (backlog [][]byte, events <-chan ringEvent, cancel func()) {
+	ch := make(chan ringEvent, ringSubscriberSize)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subs[ch] = struct{}{}
+	return r.snapshot(q), ch, func() {
+		r.mu.Lock()
+		delete(r.subs, ch)
+		r.mu.Unlock()
+	}
+}
+
+// stream writes the events matching q to w as Server-Sent Events until w
+// fails or the RingBuffer is closed.
+func (r *RingBuffer) stream(w io.Writer, flush func() error, q ringQuery, stop <-chan struct{}) {
+	backlog, events, cancel := r.follow(q)
+	defer cancel()
+
+	for _, data := range backlog {
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+	}
+	if flush() != nil {
+		return
+	}
+
+	ticker := time.NewTicker(r.cf.KeepAlive)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case evt := <-events:
+			if !q.match(evt) {
+				continue
+			}
+			_, err = fmt.Fprintf(w, "data: %s\n\n", evt.data)
+		case <-ticker.C:
+			_, err = io.WriteString(w, ": ping\n\n")
+		case <-stop:
+			return
+		case <-r.done:
+			return
+		}
+		if err != nil || flush() != nil {
+			return
+		}
+	}
+}
+
+// match reports whether evt passes q.
+func (q ringQuery) match(evt ringEvent) bool {
+	// events without a level rank above panic and pass every filter
+	if evt.level < q.min {
+		return false
+	}
+	return q.traceID == "" || evt.traceID == q.traceID
+}
+
+// parseRingQuery reads the query parameters of a request with get.
+func parseRingQuery(get func(key string) string, accept string) (ringQuery, error) {
+	q := ringQuery{min: zerolog.TraceLevel, traceID: get(TraceIDField)}
+	if s := get("level"); s != "" {
+		level, err := zerolog.ParseLevel(s)
+		if err != nil {
+			return q, fmt.Errorf("invalid level %q", s)
+		}
+		q.min = level
+	}
+	if s := get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 0 {
+			return q, fmt.Errorf("invalid limit %q", s)
+		}
+		q.limit = limit
+	}
+	stream := get("stream")
+	q.stream = stream == "1" || stream == "true" || strings.Contains(accept, "text/event-stream")
+	return q, nil
+}
+
+// authorized reports whether the request of the given Authorization header
+// and token query parameter passes the Auth hook.
+func (r *RingBuffer) authorized(authorization, token string) bool {
+	if r.cf.Auth == nil {
+		return false
+	}
+	if bearer := strings.TrimPrefix(authorization, "Bearer "); bearer != authorization {
+		token = bearer
+	}
+	return token != "" && r.cf.Auth(token)
+}
+
+// eventsJSON joins events into a JSON array.
+func eventsJSON(events [][]byte) []byte {
+	b := append([]byte{'['}, bytes.Join(events, []byte{','})...)
+	return append(b, ']')
+}
+
+// Handler serves the events of r over net/http.
+func (r *RingBuffer) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !r.authorized(req.Header.Get("Authorization"), req.URL.Query().Get("token")) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		q, err := parseRingQuery(req.URL.Query().Get, req.Header.Get("Accept"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !q.stream {
+			r.mu.Lock()
+			events := r.snapshot(q)
+			r.mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(eventsJSON(events))
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		r.stream(w, func() error { flusher.Flush(); return nil }, q, req.Context().Done())
+	})
+}
+
+// FiberHandler serves the events of r over Fiber.
+func (r *RingBuffer) FiberHandler() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if !r.authorized(ctx.Get(fiber.HeaderAuthorization), ctx.Query("token")) {
+			return fiber.ErrUnauthorized
+		}
+		// the values of ctx are reused once the handler returns, before the
+		// stream ends
+		q, err := parseRingQuery(func(key string) string { return utils.CopyString(ctx.Query(key)) }, ctx.Get(fiber.HeaderAccept))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		if !q.stream {
+			r.mu.Lock()
+			events := r.snapshot(q)
+			r.mu.Unlock()
+			ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			return ctx.Send(eventsJSON(events))
+		}
+
+		ctx.Set(fiber.HeaderContentType, "text/event-stream")
+		ctx.Set(fiber.HeaderCacheControl, "no-cache")
+		ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			// a failed flush tells the client is gone
+			r.stream(w, w.Flush, q, nil)
+		})
+		return nil
+	}
+}
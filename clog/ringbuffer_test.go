@@ -0,0 +1,125 @@
+package clog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+)
+
+func testRing() (*RingBuffer, zerolog.Logger) {
+	ring := NewRingBuffer(RingBufferConfig{Size: 4, Auth: func(token string) bool { return token == "secret" }})
+	return ring, zerolog.New(ring)
+}
+
+func ringMessages(t *testing.T, body io.Reader) []string {
+	t.Helper()
+	var events []map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, e := range events {
+		msgs = append(msgs, e[zerolog.MessageFieldName].(string))
+	}
+	return msgs
+}
+
+func TestRingBuffer_Handler(t *testing.T) {
+	ring, lg := testRing()
+	lg.Info().Msg("dropped")
+	lg.Info().Str(TraceIDField, "a1").Msg("one")
+	lg.Warn().Msg("two")
+	lg.Error().Str(TraceIDField, "a1").Msg("three")
+	lg.Debug().Msg("four")
+
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"", "[one two three four]"},
+		{"?level=warn", "[two three]"},
+		{"?traceID=a1", "[one three]"},
+		{"?limit=2", "[three four]"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/logs"+c.query, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		ring.Handler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: status %d", c.query, rec.Code)
+		}
+		if got := strings.Join(ringMessages(t, rec.Body), " "); "["+got+"]" != c.want {
+			t.Errorf("%q: got [%s], want %s", c.query, got, c.want)
+		}
+	}
+
+	for _, target := range []string{"/logs", "/logs?token=wrong"} {
+		rec := httptest.NewRecorder()
+		ring.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want 401", target, rec.Code)
+		}
+	}
+}
+
+func TestRingBuffer_Stream(t *testing.T) {
+	ring, lg := testRing()
+	defer ring.Close()
+	srv := httptest.NewServer(ring.Handler())
+	defer srv.Close()
+	lg.Info().Msg("before")
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"?token=secret&level=warn", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+
+	lg.Info().Msg("filtered")
+	lg.Warn().Msg("after")
+	r := bufio.NewReader(resp.Body)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "data: ") || !strings.Contains(line, `"after"`) {
+		t.Errorf("event = %q", line)
+	}
+}
+
+func TestRingBuffer_FiberHandler(t *testing.T) {
+	ring, lg := testRing()
+	lg.Warn().Msg("one")
+
+	app := fiber.New()
+	app.Get("/logs", ring.FiberHandler())
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/logs?token=secret&level=bogus", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", resp.StatusCode)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/logs", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if resp, err = app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	if got := ringMessages(t, resp.Body); len(got) != 1 || got[0] != "one" {
+		t.Errorf("got %v", got)
+	}
+}
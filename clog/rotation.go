@@ -0,0 +1,249 @@
+package clog
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tokens of ConfigFile.FilenameTemplate.
+const (
+	TokenService = "{service}"
+	TokenTime    = "{time}"
+	TokenHost    = "{host}"
+	TokenSeq     = "{seq}"
+)
+
+// RotationPolicy rotates a log file on the first of its triggers, next to
+// the MaxSize of the file.
+type RotationPolicy struct {
+	// Interval rotates the file at every interval of wall-clock time, aligned
+	// to midnight of the TImeZone, e.g. time.Hour or 24 * time.Hour. It is at
+	// most a day.
+	Interval time.Duration
+
+	// MaxLines rotates the file once it holds that many lines.
+	MaxLines int64
+}
+
+// periodStart returns the start of the interval of t in loc.
+func (p RotationPolicy) periodStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	if p.Interval <= 0 || p.Interval >= 24*time.Hour {
+		return midnight
+	}
+	return midnight.Add(t.Sub(midnight) / p.Interval * p.Interval)
+}
+
+// timeLayout returns the layout of the {time} token when no TimeKey is set,
+// down to the unit of the interval.
+func (p RotationPolicy) timeLayout() string {
+	switch {
+	case p.Interval <= 0 || p.Interval >= 24*time.Hour:
+		return "20060102"
+	case p.Interval >= time.Hour:
+		return "2006010215"
+	default:
+		return "200601021504"
+	}
+}
+
+// renderTemplate fills the tokens of a FilenameTemplate but {seq}, which
+// numbers the files of one rendering.
+func (l *logger) renderTemplate(t time.Time) string {
+	return strings.NewReplacer(
+		TokenService, l.Service,
+		TokenHost, l.Host,
+		TokenTime, t.In(l.TimeZone).Format(l.TimeKey),
+	).Replace(l.Template)
+}
+
+// timeKey returns the key of the files of the event time t, with the {seq}
+// token left in place.
+func (l *logger) timeKey(t time.Time) string {
+	if l.Template == "" {
+		return t.In(l.TimeZone).Format(l.TimeKey)
+	}
+	return l.renderTemplate(t)
+}
+
+// keyPattern returns the pattern of the keys written by l, with a group for
+// every {time} and {seq} token, named by tokens.
+func (l *logger) keyPattern() (re *regexp.Regexp, tokens []string) {
+	template := l.Template
+	if template == "" {
+		template = TokenTime
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for template != "" {
+		i := strings.IndexByte(template, '{')
+		j := -1
+		if i >= 0 {
+			j = strings.IndexByte(template[i:], '}')
+		}
+		if j < 0 {
+			b.WriteString(regexp.QuoteMeta(template))
+			break
+		}
+		j += i
+		b.WriteString(regexp.QuoteMeta(template[:i]))
+		switch token := template[i : j+1]; token {
+		case TokenService:
+			b.WriteString(regexp.QuoteMeta(l.Service))
+		case TokenHost:
+			b.WriteString(regexp.QuoteMeta(l.Host))
+		case TokenTime:
+			b.WriteString("(" + layoutPattern(l.TimeKey) + ")")
+			tokens = append(tokens, token)
+		case TokenSeq:
+			b.WriteString("([0-9]+)")
+			tokens = append(tokens, token)
+		default:
+			b.WriteString(regexp.QuoteMeta(token))
+		}
+		template = template[j+1:]
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String()), tokens
+}
+
+// layoutPattern returns the pattern of the times formatted with a numeric
+// layout, such as "2006010215", or a loose pattern for other layouts.
+func layoutPattern(layout string) string {
+	var b strings.Builder
+	for layout != "" {
+		switch {
+		case strings.HasPrefix(layout, "2006"):
+			b.WriteString("[0-9]{4}")
+			layout = layout[4:]
+		case strings.HasPrefix(layout, "01"), strings.HasPrefix(layout, "02"), strings.HasPrefix(layout, "15"),
+			strings.HasPrefix(layout, "04"), strings.HasPrefix(layout, "05"), strings.HasPrefix(layout, "06"):
+			b.WriteString("[0-9]{2}")
+			layout = layout[2:]
+		case strings.ContainsRune("0123456789", rune(layout[0])) || layout[0] >= 'A' && layout[0] <= 'z':
+			// unpadded, named or fractional elements
+			return ".+?"
+		default:
+			b.WriteString(regexp.QuoteMeta(layout[:1]))
+			layout = layout[1:]
+		}
+	}
+	return b.String()
+}
+
+// parseKey returns the time and sequence number of a key written by l, and
+// false for the keys of other sinks sharing the directory.
+func (l *logger) parseKey(key string) (t time.Time, seq int, ok bool) {
+	m := l.keyRe.FindStringSubmatch(key)
+	if m == nil {
+		return time.Time{}, 0, false
+	}
+	for i, token := range l.keyTokens {
+		var err error
+		if token == TokenSeq {
+			seq, err = strconv.Atoi(m[i+1])
+		} else {
+			t, err = time.ParseInLocation(l.TimeKey, m[i+1], l.TimeZone)
+		}
+		if err != nil {
+			return time.Time{}, 0, false
+		}
+	}
+	return t, seq, true
+}
+
+// hasSeq reports whether the files are numbered by {seq}.
+func (l *logger) hasSeq() bool {
+	return strings.Contains(l.Template, TokenSeq)
+}
+
+// fileKey returns the key of the current file, numbering it when the
+// template has a {seq} token.
+func (l *logger) fileKey() string {
+	key := l.timeKey(l.lastLogTime)
+	if !l.hasSeq() {
+		return key
+	}
+	if l.seq == 0 {
+		l.seq = l.resumeSeq(key)
+	}
+	return strings.ReplaceAll(key, TokenSeq, strconv.Itoa(l.seq))
+}
+
+// resumeSeq returns the sequence number to write the key under: the number
+// of its latest file, or the one after its last backup.
+func (l *logger) resumeSeq(key string) int {
+	prefix, suffix, _ := strings.Cut(key, TokenSeq)
+	files, err := ListLogFiles(l.Path)
+	if err != nil {
+		return 1
+	}
+
+	seq, latest := 0, false
+	for _, f := range files {
+		if !strings.HasPrefix(f.Key, prefix) || !strings.HasSuffix(f.Key, suffix) || len(f.Key) < len(prefix)+len(suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(f.Key[len(prefix) : len(f.Key)-len(suffix)])
+		if err != nil || n < seq {
+			continue
+		}
+		if n > seq {
+			seq, latest = n, false
+		}
+		latest = latest || f.Latest
+	}
+	if latest {
+		return seq
+	}
+	return seq + 1
+}
+
+// rotateDue reports whether writing p needs a new file.
+func (l *logger) rotateDue(p []byte) bool {
+	if l.size+l.writeSize(p) > l.max() {
+		return true
+	}
+	if l.EnableTimeKey && l.timeKey(l.lastLogTime) != l.currentTk {
+		return true
+	}
+	if l.Rotation.Interval > 0 && l.Rotation.periodStart(l.lastLogTime, l.TimeZone).After(l.period) {
+		return true
+	}
+	return l.Rotation.MaxLines > 0 && l.lines+int64(bytes.Count(p, []byte{'\n'})) > l.Rotation.MaxLines
+}
+
+// countLines returns the number of lines of the log file name.
+func (l *logger) countLines(name string) int64 {
+	rc, err := OpenLogFile(name, l.KeyProvider)
+	if err != nil {
+		return 0
+	}
+	defer rc.Close()
+
+	var lines int64
+	r := bufio.NewReader(rc)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+		if err != nil {
+			return lines
+		}
+	}
+}
+
+// finalize moves the latest file name of a key aside as a backup.
+func (l *logger) finalize(name string) error {
+	if _, err := osStat(name); err != nil {
+		return nil
+	}
+	return os.Rename(name, l.backupNameFor(name))
+}
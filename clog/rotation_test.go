@@ -0,0 +1,135 @@
+package clog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func writeAt(t *testing.T, l *logger, ts time.Time) {
+	t.Helper()
+	if _, err := fmt.Fprintf(l, `{"time":%q,"message":"x"}`+"\n", ts.Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotation_Interval(t *testing.T) {
+	fakeClock(t)
+	dir := t.TempDir()
+	tz := time.FixedZone("ICT", 7*3600)
+	l := NewLogFile(ConfigFile{
+		Filename: filepath.Join(dir, "app.log"),
+		TImeZone: tz,
+		Rotation: RotationPolicy{Interval: time.Hour},
+	})
+	defer l.Close()
+
+	writeAt(t, l, time.Date(2024, 1, 2, 8, 58, 0, 0, tz))
+	writeAt(t, l, time.Date(2024, 1, 2, 8, 59, 0, 0, tz))
+	if got := dirNames(t, dir); len(got) != 1 {
+		t.Fatalf("files = %v, want one", got)
+	}
+	writeAt(t, l, time.Date(2024, 1, 2, 9, 0, 0, 0, tz))
+	if got := dirNames(t, dir); len(got) != 2 {
+		t.Errorf("files = %v, want a backup and app.log", got)
+	}
+}
+
+func TestRotation_MaxLines(t *testing.T) {
+	fakeClock(t)
+	dir := t.TempDir()
+	l := NewLogFile(ConfigFile{Filename: filepath.Join(dir, "app.log"), Rotation: RotationPolicy{MaxLines: 2}})
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		writeAt(t, l, now)
+	}
+	l.Close()
+	if got := dirNames(t, dir); len(got) != 3 {
+		t.Fatalf("files = %v, want 3", got)
+	}
+
+	// reopening counts the lines already written
+	l = NewLogFile(ConfigFile{Filename: filepath.Join(dir, "app.log"), Rotation: RotationPolicy{MaxLines: 2}})
+	defer l.Close()
+	writeAt(t, l, now)
+	writeAt(t, l, now)
+	if got := dirNames(t, dir); len(got) != 4 {
+		t.Errorf("files = %v, want 4", got)
+	}
+}
+
+func TestFilenameTemplate(t *testing.T) {
+	fakeClock(t)
+	dir := t.TempDir()
+	tz := time.FixedZone("ICT", 7*3600)
+	cf := ConfigFile{
+		Path:             dir,
+		FilenameTemplate: "{service}-{time}-{seq}",
+		Service:          "billing",
+		TImeZone:         tz,
+		Rotation:         RotationPolicy{Interval: 24 * time.Hour, MaxLines: 1},
+	}
+	l := NewLogFile(cf)
+
+	// 23:30 UTC is already the next day in the time zone
+	day := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	writeAt(t, l, day)
+	writeAt(t, l, day)
+	writeAt(t, l, time.Date(2024, 1, 2, 23, 30, 0, 0, time.UTC))
+	l.Close()
+
+	files, err := ListLogFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var latest []string
+	var backups int
+	for _, f := range files {
+		if f.Latest {
+			latest = append(latest, f.Key)
+		} else if f.Key == "billing-20240102-1" {
+			backups++
+		}
+	}
+	sort.Strings(latest)
+	if fmt.Sprint(latest) != "[billing-20240102-2 billing-20240103-1]" || backups != 1 {
+		t.Errorf("files = %v", dirNames(t, dir))
+	}
+
+	// a restart resumes the sequence of the key
+	l = NewLogFile(cf)
+	defer l.Close()
+	writeAt(t, l, time.Date(2024, 1, 2, 23, 31, 0, 0, time.UTC))
+	if _, err := os.Stat(filepath.Join(dir, "billing-20240103-2_latest.log")); err != nil {
+		t.Errorf("files = %v", dirNames(t, dir))
+	}
+}
+
+func TestNewLogFile_TimeZone(t *testing.T) {
+	tz := time.FixedZone("EST", -5*3600)
+	l := NewLogFile(ConfigFile{EnableTimeKey: true, TimeKey: "2006010215", Path: t.TempDir(), TImeZone: tz})
+	defer l.Close()
+	writeAt(t, l, time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC))
+	writeAt(t, l, time.Date(2024, 1, 2, 3, 10, 0, 0, time.UTC))
+
+	if got := dirNames(t, l.Path); len(got) != 1 || got[0] != "2024010122_latest.log" {
+		t.Errorf("files = %v, want 2024010122_latest.log", got)
+	}
+}
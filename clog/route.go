@@ -0,0 +1,193 @@
+package clog
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ensure we always implement io.WriteCloser
+var _ io.WriteCloser = (*RouteWriter)(nil)
+
+// RouteConfig configures a RouteWriter.
+type RouteConfig struct {
+	// Field is the event field routed on, e.g. "operator" or "tenant".
+	Field string
+
+	// Routes maps a value of Field to the file sink of that route. Each route
+	// has its own rotation and retention settings.
+	Routes map[string]ConfigFile
+
+	// Default receives the events whose Field is missing or has no route.
+	Default ConfigFile
+
+	// MaxOpen caps the number of route files open at the same time. The least
+	// recently used route is closed first and reopened on its next event.
+	// The default is no cap.
+	MaxOpen int
+}
+
+// RouteWriter sends every log event to a separate rotating file sink chosen
+// by the value of one of its fields.
+//
+//	routes := clog.NewRouteWriter(clog.RouteConfig{
+//		Field:   "operator",
+//		Routes:  map[string]clog.ConfigFile{"op-1": {Filename: "./logs/op-1/app.log", MaxAge: 30}},
+//		Default: clog.ConfigFile{Filename: "./logs/app.log", MaxAge: 5},
+//		MaxOpen: 64,
+//	})
+//	multi := zerolog.MultiLevelWriter(os.Stdout, routes)
+type RouteWriter struct {
+	field   string
+	configs map[string]ConfigFile
+	def     ConfigFile
+	maxOpen int
+
+	mu    sync.Mutex
+	sinks map[string]*logger
+	lru   *list.List
+	open  map[string]*list.Element
+}
+
+// defaultRoute is the route key of RouteConfig.Default.
+const defaultRoute = "\x00default"
+
+func NewRouteWriter(cf RouteConfig) *RouteWriter {
+	if cf.Field == "" {
+		panic("route field is required")
+	}
+
+	configs := make(map[string]ConfigFile, len(cf.Routes))
+	for value, c := range cf.Routes {
+		configs[value] = c
+	}
+	return &RouteWriter{
+		field:   cf.Field,
+		configs: configs,
+		def:     cf.Default,
+		maxOpen: cf.MaxOpen,
+		sinks:   map[string]*logger{},
+		lru:     list.New(),
+		open:    map[string]*list.Element{},
+	}
+}
+
+// Write implements io.Writer, writing p to the sink of its route.
+func (r *RouteWriter) Write(p []byte) (n int, err error) {
+	route, err := r.route(p)
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sink := r.sink(route)
+	r.touch(route)
+	return sink.Write(p)
+}
+
+// Close implements io.Closer, and closes the files of every route.
+func (r *RouteWriter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	for _, sink := range r.sinks {
+		if errClose := sink.Close(); err == nil && errClose != nil {
+			err = errClose
+		}
+	}
+	r.lru.Init()
+	r.open = map[string]*list.Element{}
+	return err
+}
+
+// Shutdown shuts the files of every route down within ctx. Writes are not
+// held up while it waits: a route written meanwhile reopens its file.
+func (r *RouteWriter) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	sinks := make([]*logger, 0, len(r.sinks))
+	for _, sink := range r.sinks {
+		sinks = append(sinks, sink)
+	}
+	r.lru.Init()
+	r.open = map[string]*list.Element{}
+	r.mu.Unlock()
+
+	var errs shutdownErrors
+	for _, sink := range sinks {
+		if err := sink.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// route returns the route key of the event p.
+func (r *RouteWriter) route(p []byte) (string, error) {
+	raw, found, ok := scanField(p, r.field)
+	if !ok {
+		var evt map[string]json.RawMessage
+		if err := json.Unmarshal(p, &evt); err != nil {
+			return "", fmt.Errorf("cannot decode event: %s", err)
+		}
+		raw, found = evt[r.field]
+	}
+	if !found {
+		return defaultRoute, nil
+	}
+
+	value, ok := unquoteField(raw)
+	if !ok {
+		value = string(raw)
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			value = s
+		}
+	}
+	if _, ok := r.configs[value]; !ok {
+		return defaultRoute, nil
+	}
+	return value, nil
+}
+
+// sink returns the file sink of route, creating it on first use.
+func (r *RouteWriter) sink(route string) *logger {
+	if sink, ok := r.sinks[route]; ok {
+		return sink
+	}
+
+	cf, ok := r.configs[route]
+	if !ok {
+		cf = r.def
+	}
+	sink := NewLogFile(cf)
+	r.sinks[route] = sink
+	return sink
+}
+
+// touch marks route as most recently used, closing the least recently used
+// route files once more than maxOpen are open.
+func (r *RouteWriter) touch(route string) {
+	if e, ok := r.open[route]; ok {
+		r.lru.MoveToFront(e)
+		return
+	}
+	r.open[route] = r.lru.PushFront(route)
+
+	for r.maxOpen > 0 && r.lru.Len() > r.maxOpen {
+		oldest := r.lru.Back()
+		key := oldest.Value.(string)
+		r.lru.Remove(oldest)
+		delete(r.open, key)
+		// what am I going to do, log this?
+		_ = r.sinks[key].Close()
+	}
+}
@@ -0,0 +1,59 @@
+package clog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRouteWriter(t *testing.T) {
+	dir := t.TempDir()
+	rw := NewRouteWriter(RouteConfig{
+		Field: "operator",
+		Routes: map[string]ConfigFile{
+			"op-1": {Filename: filepath.Join(dir, "op-1", "app.log")},
+			"op-2": {Filename: filepath.Join(dir, "op-2", "app.log")},
+		},
+		Default: ConfigFile{Filename: filepath.Join(dir, "app.log")},
+		MaxOpen: 1,
+	})
+	defer rw.Close()
+
+	lg := zerolog.New(rw).With().Timestamp().Logger()
+	lg.Info().Str("operator", "op-1").Msg("first")
+	lg.Info().Str("operator", "op-2").Msg("second")
+	lg.Info().Str("operator", "op-1").Msg("third")
+	lg.Info().Str("operator", "unknown").Msg("fourth")
+	lg.Info().Msg("fifth")
+
+	if got := len(rw.open); got != 1 {
+		t.Errorf("open routes = %d, want 1", got)
+	}
+
+	cases := []struct {
+		file string
+		want []string
+	}{
+		{"op-1/app.log", []string{"first", "third"}},
+		{"op-2/app.log", []string{"second"}},
+		{"app.log", []string{"fourth", "fifth"}},
+	}
+	for _, c := range cases {
+		b, err := os.ReadFile(filepath.Join(dir, c.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if len(lines) != len(c.want) {
+			t.Fatalf("%s: got %d lines, want %d", c.file, len(lines), len(c.want))
+		}
+		for i, msg := range c.want {
+			if !strings.Contains(lines[i], `"message":"`+msg+`"`) {
+				t.Errorf("%s line %d = %s, want message %q", c.file, i, lines[i], msg)
+			}
+		}
+	}
+}
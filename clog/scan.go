@@ -0,0 +1,154 @@
+package clog
+
+import (
+	"bytes"
+)
+
+// scanField returns the raw value of the top-level key of the JSON object p,
+// the last one if the key is repeated, as decoding p into a map would. found
+// is false when p has no such key. ok is false when p is not an object the
+// scanner understands, e.g. malformed JSON or escaped keys, and the caller
+// should decode p instead.
+func scanField(p []byte, key string) (raw []byte, found, ok bool) {
+	i := skipSpace(p, 0)
+	if i >= len(p) || p[i] != '{' {
+		return nil, false, false
+	}
+	i = skipSpace(p, i+1)
+	if i < len(p) && p[i] == '}' {
+		return nil, false, true
+	}
+
+	for {
+		if i >= len(p) || p[i] != '"' {
+			return nil, false, false
+		}
+		end := skipString(p, i)
+		if end < 0 {
+			return nil, false, false
+		}
+		k := p[i+1 : end-1]
+		if bytes.IndexByte(k, '\\') >= 0 {
+			return nil, false, false
+		}
+
+		i = skipSpace(p, end)
+		if i >= len(p) || p[i] != ':' {
+			return nil, false, false
+		}
+		i = skipSpace(p, i+1)
+		start := i
+		if i = skipValue(p, i); i < 0 {
+			return nil, false, false
+		}
+		if string(k) == key {
+			raw, found = p[start:i], true
+		}
+
+		i = skipSpace(p, i)
+		if i >= len(p) {
+			return nil, false, false
+		}
+		switch p[i] {
+		case ',':
+			i = skipSpace(p, i+1)
+		case '}':
+			return raw, found, true
+		default:
+			return nil, false, false
+		}
+	}
+}
+
+// unquoteField returns the content of the raw JSON string s, ok being false
+// for strings with escapes.
+func unquoteField(s []byte) (string, bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", false
+	}
+	s = s[1 : len(s)-1]
+	if bytes.IndexByte(s, '\\') >= 0 {
+		return "", false
+	}
+	return string(s), true
+}
+
+func skipSpace(p []byte, i int) int {
+	for i < len(p) {
+		switch p[i] {
+		case ' ', '\t', '\r', '\n':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipString returns the index after the string starting at p[i], or -1.
+func skipString(p []byte, i int) int {
+	for i++; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// skipValue returns the index after the value starting at p[i], or -1.
+func skipValue(p []byte, i int) int {
+	if i >= len(p) {
+		return -1
+	}
+	switch p[i] {
+	case '"':
+		return skipString(p, i)
+	case '{', '[':
+		depth := 0
+		for ; i < len(p); i++ {
+			switch p[i] {
+			case '"':
+				if i = skipString(p, i); i < 0 {
+					return -1
+				}
+				i--
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return -1
+	case 't':
+		return skipLiteral(p, i, "true")
+	case 'f':
+		return skipLiteral(p, i, "false")
+	case 'n':
+		return skipLiteral(p, i, "null")
+	default:
+		start := i
+		for ; i < len(p); i++ {
+			c := p[i]
+			if (c < '0' || c > '9') && c != '-' && c != '+' && c != '.' && c != 'e' && c != 'E' {
+				break
+			}
+		}
+		if i == start {
+			return -1
+		}
+		return i
+	}
+}
+
+func skipLiteral(p []byte, i int, lit string) int {
+	if !bytes.HasPrefix(p[i:], []byte(lit)) {
+		return -1
+	}
+	return i + len(lit)
+}
@@ -0,0 +1,49 @@
+package clog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var eventTimeCases = []string{
+	`{"level":"info","time":1704186000,"message":"x"}` + "\n",
+	`{"level":"info","time":1704186000,"caller":"a.go:1","time":1704186060}`,
+	`{"md":{"time":"nested"},"req":{"a":[1,{"time":5}],"s":"}\"{"},"time":1704186000}`,
+	`  { "time" : 1704186000 , "ok" : true, "n": null, "f": -1.5e3 }`,
+	`{"time":"2024-01-02T15:04:05+07:00"}`,
+	`{"time":true}`,
+	`{"time":1704186000}`,
+	`{"message":"x","time":1704186000} trailing`,
+}
+
+func TestEventTime_MatchesDecode(t *testing.T) {
+	prev := zerolog.TimeFieldFormat
+	zerolog.TimeFieldFormat = time.RFC3339
+	defer func() { zerolog.TimeFieldFormat = prev }()
+
+	for _, line := range eventTimeCases {
+		want, wantErr := decodeEventTime([]byte(line))
+		got, err := eventTime([]byte(line))
+		if (err != nil) != (wantErr != nil) || !got.Equal(want) {
+			t.Errorf("%s: got (%v, %v), want (%v, %v)", line, got, err, want, wantErr)
+		}
+	}
+
+	for _, bad := range []string{`{"time":1`, `[1,2]`, `{"time":tru}`, ``} {
+		if _, err := eventTime([]byte(bad)); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestScanField(t *testing.T) {
+	raw, found, ok := scanField([]byte(`{"operator":"op-1","x":{"operator":"nested"}}`), "operator")
+	if !ok || !found || string(raw) != `"op-1"` {
+		t.Errorf("got %s, %v, %v", raw, found, ok)
+	}
+	if _, found, ok := scanField([]byte(`{"x":1}`), "operator"); !ok || found {
+		t.Errorf("missing field: found=%v ok=%v", found, ok)
+	}
+}
@@ -0,0 +1,278 @@
+package clog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ensure we always implement zerolog.LevelWriter
+var _ zerolog.LevelWriter = (*SchemaWriter)(nil)
+
+// Schema names the field layout of the events written to a sink.
+type Schema string
+
+const (
+	// SchemaClog writes the events as logged.
+	SchemaClog Schema = ""
+	// SchemaECS writes Elastic Common Schema documents.
+	SchemaECS Schema = "ecs"
+	// SchemaOTel writes OpenTelemetry log records.
+	SchemaOTel Schema = "otel"
+
+	// ecsVersion is the version of ECS the fields follow.
+	ecsVersion = "8.11.0"
+)
+
+// schemaField maps a clog field to the names of the schemas.
+type schemaField struct {
+	clog *string
+	ecs  string
+	otel string
+	// resource fields describe the process in OTel records
+	resource bool
+}
+
+// schemaFields are the clog fields the schemas rename. The other fields keep
+// their names.
+var schemaFields = []schemaField{
+	{clog: &TraceIDField, ecs: "trace.id", otel: "TraceId"},
+	{clog: &UserAgentField, ecs: "user_agent.original", otel: "user_agent.original"},
+	{clog: &IPField, ecs: "client.ip", otel: "client.address"},
+	{clog: &ForwardedForField, ecs: "network.forwarded_ip", otel: "http.request.header.x-forwarded-for"},
+	{clog: &MetadataField, ecs: "rpc.grpc.request.metadata", otel: "rpc.grpc.request.metadata"},
+	{clog: &ReqField, ecs: "http.request.body.content", otel: "http.request.body.content"},
+	{clog: &RespField, ecs: "http.response.body.content", otel: "http.response.body.content"},
+	{clog: &ServiceField, ecs: "rpc.service", otel: "rpc.service"},
+	{clog: &CodeField, ecs: "rpc.grpc.status_code", otel: "rpc.grpc.status_code"},
+	{clog: &PathField, ecs: "url.path", otel: "url.path"},
+	{clog: &StatusField, ecs: "http.response.status_code", otel: "http.response.status_code"},
+	{clog: &URLField, ecs: "url.full", otel: "url.full"},
+	{clog: &QueryField, ecs: "db.statement", otel: "db.statement"},
+	{clog: &DatabaseField, ecs: "db.name", otel: "db.name"},
+	{clog: &CollectionField, ecs: "db.mongodb.collection", otel: "db.mongodb.collection"},
+	{clog: &CommandField, ecs: "db.operation", otel: "db.operation"},
+	{clog: &SeqField, ecs: "event.sequence", otel: "log.record.sequence"},
+	{clog: &AppField, ecs: "service.name", otel: "service.name", resource: true},
+	{clog: &VersionField, ecs: "service.version", otel: "service.version", resource: true},
+	{clog: &EnvField, ecs: "service.environment", otel: "deployment.environment", resource: true},
+	{clog: &HostField, ecs: "host.hostname", otel: "host.name", resource: true},
+	{clog: &PIDField, ecs: "process.pid", otel: "process.pid", resource: true},
+	{clog: &PodField, ecs: "kubernetes.pod.name", otel: "k8s.pod.name", resource: true},
+	{clog: &NamespaceField, ecs: "kubernetes.namespace", otel: "k8s.namespace.name", resource: true},
+}
+
+// valid reports whether s is a known schema.
+func (s Schema) valid() bool {
+	return s == SchemaClog || s == SchemaECS || s == SchemaOTel
+}
+
+// SchemaWriter rewrites the events written to a sink to a Schema, so a sink
+// can feed a log platform without changing the calling code.
+//
+//	ecs := clog.NewSchemaWriter(clog.NewLogFile(cf), clog.SchemaECS)
+type SchemaWriter struct {
+	w      zerolog.LevelWriter
+	schema Schema
+}
+
+func NewSchemaWriter(w io.Writer, schema Schema) *SchemaWriter {
+	if !schema.valid() {
+		panic(fmt.Sprintf("unknown schema %q", schema))
+	}
+	return &SchemaWriter{w: levelWriter(w), schema: schema}
+}
+
+// Write implements io.Writer, taking the level from the event.
+func (s *SchemaWriter) Write(p []byte) (n int, err error) {
+	return s.WriteLevel(eventLevel(p), p)
+}
+
+// WriteLevel implements zerolog.LevelWriter.
+func (s *SchemaWriter) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
+	if s.schema == SchemaClog {
+		return s.w.WriteLevel(level, p)
+	}
+	out, err := s.schema.convert(level, p)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.w.WriteLevel(level, out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer, closing the sink when it is a closer.
+func (s *SchemaWriter) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Shutdown shuts the sink down within ctx.
+func (s *SchemaWriter) Shutdown(ctx context.Context) error {
+	return shutdownSink(ctx, s.w)
+}
+
+// convert rewrites the event p to s.
+func (s Schema) convert(level zerolog.Level, p []byte) ([]byte, error) {
+	evt, err := decodeEvent(p)
+	if err != nil {
+		return nil, err
+	}
+	if level == zerolog.NoLevel {
+		if l, err := zerolog.ParseLevel(fieldString(evt[zerolog.LevelFieldName])); err == nil {
+			level = l
+		}
+	}
+
+	var out map[string]interface{}
+	if s == SchemaECS {
+		out = ecsEvent(level, sinkEventTime(p), evt)
+	} else {
+		out = otelEvent(level, sinkEventTime(p), evt)
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// ecsEvent maps evt to an ECS document.
+func ecsEvent(level zerolog.Level, t time.Time, evt map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{
+		"@timestamp":  t.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		"ecs.version": ecsVersion,
+	}
+	if level != zerolog.NoLevel {
+		out["log.level"] = level.String()
+	}
+	if msg, ok := evt[zerolog.MessageFieldName]; ok {
+		out["message"] = msg
+	}
+	commonFields(out, evt, func(f schemaField) string { return f.ecs }, "error.message", "error.stack_trace", "log.origin.file.name", "log.origin.file.line")
+	return out
+}
+
+// otelEvent maps evt to an OpenTelemetry log record.
+func otelEvent(level zerolog.Level, t time.Time, evt map[string]interface{}) map[string]interface{} {
+	number, text := otelSeverity(level)
+	out := map[string]interface{}{
+		"Timestamp":      strconv.FormatInt(t.UnixNano(), 10),
+		"SeverityText":   text,
+		"SeverityNumber": number,
+	}
+	if msg, ok := evt[zerolog.MessageFieldName]; ok {
+		out["Body"] = msg
+	}
+
+	attrs := map[string]interface{}{}
+	resource := map[string]interface{}{}
+	for _, f := range schemaFields {
+		if v, ok := evt[*f.clog]; ok && f.resource {
+			resource[f.otel] = v
+			delete(evt, *f.clog)
+		}
+	}
+	commonFields(attrs, evt, func(f schemaField) string { return f.otel }, "exception.message", "exception.stacktrace", "code.filepath", "code.lineno")
+	if traceID, ok := attrs["TraceId"]; ok {
+		out["TraceId"] = traceID
+		delete(attrs, "TraceId")
+	}
+	if len(attrs) > 0 {
+		out["Attributes"] = attrs
+	}
+	if len(resource) > 0 {
+		out["Resource"] = resource
+	}
+	return out
+}
+
+// commonFields maps the fields of evt both schemas share to out, naming them
+// with name and the given error, stack and caller keys.
+func commonFields(out, evt map[string]interface{}, name func(schemaField) string, errKey, stackKey, fileKey, lineKey string) {
+	mapped := map[string]string{}
+	for _, f := range schemaFields {
+		mapped[*f.clog] = name(f)
+	}
+
+	for k, v := range evt {
+		switch k {
+		case zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName:
+		case zerolog.ErrorFieldName:
+			out[errKey] = v
+		case zerolog.ErrorStackFieldName:
+			out[stackKey] = fieldString(v)
+		case zerolog.CallerFieldName:
+			caller := fieldString(v)
+			if i := strings.LastIndexByte(caller, ':'); i > 0 {
+				if line, err := strconv.Atoi(caller[i+1:]); err == nil {
+					out[fileKey] = caller[:i]
+					out[lineKey] = line
+					continue
+				}
+			}
+			out[fileKey] = caller
+		case DurationField:
+			out["event.duration"] = durationNanos(v)
+		case MethodField:
+			// gRPC events carry a service, HTTP events a path or a url
+			if _, ok := evt[ServiceField]; ok {
+				out["rpc.method"] = v
+			} else {
+				out["http.request.method"] = v
+			}
+		default:
+			if to, ok := mapped[k]; ok {
+				out[to] = v
+			} else {
+				out[k] = v
+			}
+		}
+	}
+}
+
+// durationNanos converts a duration logged in zerolog.DurationFieldUnit to
+// nanoseconds.
+func durationNanos(v interface{}) interface{} {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return v
+	}
+	return int64(f * float64(zerolog.DurationFieldUnit))
+}
+
+// otelSeverity maps a zerolog level to an OpenTelemetry severity.
+func otelSeverity(level zerolog.Level) (int, string) {
+	switch level {
+	case zerolog.TraceLevel:
+		return 1, "TRACE"
+	case zerolog.DebugLevel:
+		return 5, "DEBUG"
+	case zerolog.InfoLevel:
+		return 9, "INFO"
+	case zerolog.WarnLevel:
+		return 13, "WARN"
+	case zerolog.ErrorLevel:
+		return 17, "ERROR"
+	case zerolog.FatalLevel:
+		return 21, "FATAL"
+	case zerolog.PanicLevel:
+		return 24, "PANIC"
+	default:
+		return 0, ""
+	}
+}
@@ -0,0 +1,104 @@
+package clog
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func schemaEvent(t *testing.T, schema Schema, log func(zerolog.Logger)) map[string]interface{} {
+	t.Helper()
+	var buf bytes.Buffer
+	log(zerolog.New(NewSchemaWriter(&buf, schema)).With().Timestamp().Logger())
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("invalid event %q: %v", buf.String(), err)
+	}
+	return m
+}
+
+func TestSchemaWriter_ECS(t *testing.T) {
+	m := schemaEvent(t, SchemaECS, func(lg zerolog.Logger) {
+		lg.Warn().Str(TraceIDField, "abcd1234").Str(MethodField, "GET").Str(PathField, "/users").
+			Int(StatusField, 404).Dur(DurationField, 1500*time.Millisecond).Str(UserAgentField, "curl/8").
+			Str(AppField, "billing").Str("tenant", "t1").Msg("not found")
+	})
+	want := map[string]interface{}{
+		"log.level":                 "warn",
+		"message":                   "not found",
+		"trace.id":                  "abcd1234",
+		"http.request.method":       "GET",
+		"url.path":                  "/users",
+		"http.response.status_code": float64(404),
+		"event.duration":            float64(1500 * time.Millisecond),
+		"user_agent.original":       "curl/8",
+		"service.name":              "billing",
+		"tenant":                    "t1",
+		"ecs.version":               ecsVersion,
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("%s = %v, want %v", k, m[k], v)
+		}
+	}
+	if _, ok := m["@timestamp"]; !ok {
+		t.Error("missing @timestamp")
+	}
+	for _, k := range []string{TraceIDField, DurationField, zerolog.LevelFieldName} {
+		if _, ok := m[k]; ok {
+			t.Errorf("clog field %s kept", k)
+		}
+	}
+
+	// HTTP client events have a url but no path
+	m = schemaEvent(t, SchemaECS, func(lg zerolog.Logger) {
+		lg.Info().Str(MethodField, "POST").Str(URLField, "https://api.example.com/pay").Msg(ClientMessageDefault)
+	})
+	if m["http.request.method"] != "POST" || m["url.full"] != "https://api.example.com/pay" || m["rpc.method"] != nil {
+		t.Errorf("http client event = %v", m)
+	}
+
+	// gRPC events have a service
+	m = schemaEvent(t, SchemaECS, func(lg zerolog.Logger) {
+		lg.Info().Str(ServiceField, "pkg.Service").Str(MethodField, "Get").Msg(UnaryMessageDefault)
+	})
+	if m["rpc.service"] != "pkg.Service" || m["rpc.method"] != "Get" {
+		t.Errorf("grpc event = %v", m)
+	}
+}
+
+func TestSchemaWriter_OTel(t *testing.T) {
+	m := schemaEvent(t, SchemaOTel, func(lg zerolog.Logger) {
+		lg.Error().Str(TraceIDField, "abcd1234").Str(HostField, "web-1").Int(PIDField, 42).
+			Str(QueryField, "SELECT 1").Msg("query failed")
+	})
+	if m["SeverityText"] != "ERROR" || m["SeverityNumber"] != float64(17) {
+		t.Errorf("severity = %v %v", m["SeverityText"], m["SeverityNumber"])
+	}
+	if m["Body"] != "query failed" || m["TraceId"] != "abcd1234" {
+		t.Errorf("record = %v", m)
+	}
+	resource, _ := m["Resource"].(map[string]interface{})
+	if resource["host.name"] != "web-1" || resource["process.pid"] != float64(42) {
+		t.Errorf("resource = %v", m["Resource"])
+	}
+	attrs, _ := m["Attributes"].(map[string]interface{})
+	if attrs["db.statement"] != "SELECT 1" {
+		t.Errorf("attributes = %v", m["Attributes"])
+	}
+	if _, ok := attrs["TraceId"]; ok {
+		t.Error("trace ID kept in attributes")
+	}
+	if _, ok := m["Timestamp"].(string); !ok {
+		t.Errorf("timestamp = %v", m["Timestamp"])
+	}
+}
+
+func TestNewLogger_UnknownSchema(t *testing.T) {
+	if _, err := NewLogger(Options{Format: FormatJson, Schema: "gelf"}); err == nil {
+		t.Error("unknown schema should fail")
+	}
+}
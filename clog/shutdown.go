@@ -0,0 +1,71 @@
+package clog
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// shutdowner is a sink finishing its background work within a context.
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Shutdown shuts the default Logger down. See Logger.Shutdown.
+func Shutdown(ctx context.Context) error {
+	return Default().Shutdown(ctx)
+}
+
+// Shutdown flushes the buffered events of the sinks of l, waits for their
+// background work, such as the compression of rotated files, and closes them.
+// It returns when done or when ctx is done, reporting every error.
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	if err := clog.Shutdown(ctx); err != nil {
+//		fmt.Fprintln(os.Stderr, err)
+//	}
+func (l *Logger) Shutdown(ctx context.Context) error {
+	var errs shutdownErrors
+	for _, c := range l.closers {
+		if err := shutdownSink(ctx, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// shutdownSink shuts w down within ctx. Sinks that cannot be shut down
+// within a context are closed in the background while waiting for ctx. When
+// ctx ends first, that goroutine is abandoned: it runs until Close returns,
+// which a stuck sink may never do.
+func shutdownSink(ctx context.Context, w interface{}) error {
+	switch w := w.(type) {
+	case shutdowner:
+		return w.Shutdown(ctx)
+	case io.Closer:
+		done := make(chan error, 1)
+		go func() { done <- w.Close() }()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// shutdownErrors are the errors of the sinks shut down together.
+type shutdownErrors []error
+
+func (e shutdownErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "clog shutdown: " + strings.Join(msgs, "; ")
+}
@@ -0,0 +1,80 @@
+package clog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingSink never finishes closing.
+type blockingSink struct{}
+
+func (blockingSink) Write(p []byte) (int, error) { return len(p), nil }
+func (blockingSink) Close() error                { select {} }
+
+func TestLogger_Shutdown(t *testing.T) {
+	fakeClock(t)
+
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	sink := NewHTTPSink(HTTPSinkConfig{URL: srv.URL, Encoder: ElasticEncoder{Index: "logs"}, FlushInterval: time.Hour})
+
+	dir := t.TempDir()
+	lg, err := NewLogger(Options{
+		Format: FormatJson,
+		Files:  []FileSink{{ConfigFile: ConfigFile{Filename: filepath.Join(dir, "app.log"), MaxSize: "300", Compress: true}}},
+		Sinks:  []io.Writer{sink},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		lg.GetLog().Info().Int("i", i).Msg("hello")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := lg.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := sink.Stats(); stats.Sent != 10 {
+		t.Errorf("http sink sent %d events, want 10", stats.Sent)
+	}
+	entries, _ := os.ReadDir(dir)
+	var plain int
+	for _, e := range entries {
+		if f, ok := parseLogName(e.Name()); ok && f.Backup() && !f.Compressed {
+			plain++
+		}
+	}
+	if len(entries) < 2 || plain != 0 {
+		t.Errorf("files = %d, uncompressed backups = %d, want every backup compressed", len(entries), plain)
+	}
+}
+
+func TestLogger_ShutdownDeadline(t *testing.T) {
+	lg, err := NewLogger(Options{Format: FormatJson, Sinks: []io.Writer{blockingSink{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = lg.Shutdown(ctx)
+	if !strings.Contains(fmt.Sprint(err), "deadline") {
+		t.Fatalf("err = %v, want the deadline reported", err)
+	}
+	var errs shutdownErrors
+	if !errors.As(err, &errs) || !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Errorf("err = %#v", err)
+	}
+}
@@ -0,0 +1,387 @@
+package clog
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	// QueryField key of the SQL statement.
+	QueryField = "query"
+	// ArgsField key of the SQL arguments.
+	ArgsField = "args"
+	// RowsField key of the rows affected.
+	RowsField = "rows"
+	// SQLMessageDefault of logging SQL statements.
+	SQLMessageDefault = "sql"
+)
+
+// SQLConfig configures the database/sql driver wrappers.
+type SQLConfig struct {
+	// SlowThreshold logs the statements slower than it at warn level. Zero
+	// disables it. Failed statements are logged at error level, the others
+	// at debug level.
+	SlowThreshold time.Duration
+
+	// LogArgs logs the statement arguments. The arguments bound to a column
+	// or name of RedactKeys, as in "password = $2" or
+	// "INSERT INTO users (name, pin) VALUES (?, ?)", are redacted.
+	LogArgs bool
+}
+
+// WrapDriver returns a driver logging the statements run through d with the
+// logger and trace ID of their context.
+//
+//	sql.Register("postgres-clog", clog.WrapDriver(&pq.Driver{}, clog.SQLConfig{SlowThreshold: 200 * time.Millisecond}))
+//	db, err := sql.Open("postgres-clog", dsn)
+func WrapDriver(d driver.Driver, cf SQLConfig) driver.Driver {
+	return &sqlDriver{d: d, cf: cf}
+}
+
+// WrapConnector returns a connector logging the statements run through the
+// connections of c, for sql.OpenDB.
+//
+//	db := sql.OpenDB(clog.WrapConnector(connector, clog.SQLConfig{LogArgs: true}))
+func WrapConnector(c driver.Connector, cf SQLConfig) driver.Connector {
+	return &sqlConnector{c: c, d: &sqlDriver{d: c.Driver(), cf: cf}}
+}
+
+type sqlDriver struct {
+	d  driver.Driver
+	cf SQLConfig
+}
+
+func (d *sqlDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.d.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{conn: conn, cf: d.cf}, nil
+}
+
+// OpenConnector implements driver.DriverContext.
+func (d *sqlDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.d.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &sqlConnector{c: c, d: d}, nil
+	}
+	return &sqlConnector{d: d, name: name}, nil
+}
+
+type sqlConnector struct {
+	c    driver.Connector
+	d    *sqlDriver
+	name string
+}
+
+func (c *sqlConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.c == nil {
+		return c.d.Open(c.name)
+	}
+	conn, err := c.c.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{conn: conn, cf: c.d.cf}, nil
+}
+
+func (c *sqlConnector) Driver() driver.Driver {
+	return c.d
+}
+
+// sqlConn wraps a connection, implementing the optional interfaces of
+// database/sql by delegating to the connection when it implements them too.
+type sqlConn struct {
+	conn driver.Conn
+	cf   SQLConfig
+}
+
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStmt{stmt: stmt, query: query, cf: c.cf}, nil
+}
+
+func (c *sqlConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(0) || opts.ReadOnly {
+		return nil, errors.New("sql: driver does not support transaction options")
+	}
+	return c.conn.Begin()
+}
+
+func (c *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	now := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		logSQL(ctx, c.cf, query, args, now, res, err)
+	}
+	return res, err
+}
+
+func (c *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	now := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		logSQL(ctx, c.cf, query, args, now, nil, err)
+	}
+	return rows, err
+}
+
+func (c *sqlConn) Ping(ctx context.Context) error {
+	if p, ok := c.conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) IsValid() bool {
+	if v, ok := c.conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *sqlConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type sqlStmt struct {
+	stmt  driver.Stmt
+	query string
+	cf    SQLConfig
+}
+
+func (s *sqlStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *sqlStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	now := time.Now()
+	var res driver.Result
+	var err error
+	if e, ok := s.stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		res, err = s.stmt.Exec(values(args))
+	}
+	logSQL(ctx, s.cf, s.query, args, now, res, err)
+	return res, err
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	now := time.Now()
+	var rows driver.Rows
+	var err error
+	if q, ok := s.stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.stmt.Query(values(args))
+	}
+	logSQL(ctx, s.cf, s.query, args, now, nil, err)
+	return rows, err
+}
+
+func (s *sqlStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vs := make([]driver.Value, len(args))
+	for i, nv := range args {
+		vs[i] = nv.Value
+	}
+	return vs
+}
+
+// logSQL logs a statement run since now.
+func logSQL(ctx context.Context, cf SQLConfig, query string, args []driver.NamedValue, now time.Time, res driver.Result, err error) {
+	dur := time.Since(now)
+	log := contextLog(ctx)
+	var e *zerolog.Event
+	switch {
+	case err != nil:
+		e = log.Error().Err(err)
+	case cf.SlowThreshold > 0 && dur >= cf.SlowThreshold:
+		e = log.Warn()
+	default:
+		e = log.Debug()
+	}
+	if !e.Enabled() {
+		return
+	}
+
+	e = withTraceID(ctx, e).Str(QueryField, query)
+	if cf.LogArgs && len(args) > 0 {
+		e = e.Strs(ArgsField, sqlArgs(query, args))
+	}
+	if res != nil {
+		if n, err := res.RowsAffected(); err == nil {
+			e = e.Int64(RowsField, n)
+		}
+	}
+	e.Dur(DurationField, dur).Msg(SQLMessageDefault)
+}
+
+var (
+	// sqlCompared matches a column compared to a placeholder.
+	sqlCompared = regexp.MustCompile(`(?i)([\w."` + "`" + `]+)\s*(?:=|<>|!=|<=|>=|<|>|\blike\b|\bin\s*\()\s*(\$\d+|\?|[:@]\w+)`)
+	// sqlInsert matches the columns and values of an INSERT.
+	sqlInsert = regexp.MustCompile(`(?is)\binsert\s+into\s+\S+\s*\(([^)]*)\)\s*values\s*\(([^)]*)\)`)
+	// sqlPlaceholder matches a placeholder.
+	sqlPlaceholder = regexp.MustCompile(`\$\d+|\?|[:@]\w+`)
+)
+
+// sqlArgs returns the arguments of query to log, redacting those bound to a
+// column or name of RedactKeys.
+func sqlArgs(query string, args []driver.NamedValue) []string {
+	names := sqlArgNames(query)
+	out := make([]string, len(args))
+	for i, arg := range args {
+		name := arg.Name
+		if name == "" {
+			name = names[strconv.Itoa(arg.Ordinal)]
+		}
+		if redacted(name) || redacted(names[":"+arg.Name]) {
+			out[i] = RedactedValue
+			continue
+		}
+		out[i] = sqlArg(arg.Value)
+	}
+	return out
+}
+
+// sqlArgNames maps the placeholders of query to the columns they are bound
+// to. Positional placeholders are keyed by ordinal, named ones by ":name".
+func sqlArgNames(query string) map[string]string {
+	names := map[string]string{}
+	key := func(placeholder string, pos int) string {
+		switch placeholder[0] {
+		case '$':
+			return placeholder[1:]
+		case '?':
+			return strconv.Itoa(strings.Count(query[:pos], "?") + 1)
+		default:
+			return ":" + placeholder[1:]
+		}
+	}
+	column := func(s string) string {
+		s = strings.Trim(strings.TrimSpace(s), "\"`")
+		if i := strings.LastIndexByte(s, '.'); i >= 0 {
+			s = strings.Trim(s[i+1:], "\"`")
+		}
+		return s
+	}
+
+	for _, m := range sqlCompared.FindAllStringSubmatchIndex(query, -1) {
+		names[key(query[m[4]:m[5]], m[4])] = column(query[m[2]:m[3]])
+	}
+	for _, m := range sqlInsert.FindAllStringSubmatchIndex(query, -1) {
+		cols := strings.Split(query[m[2]:m[3]], ",")
+		vals := strings.Split(query[m[4]:m[5]], ",")
+		if len(cols) != len(vals) {
+			continue
+		}
+		pos := m[4]
+		for i, v := range vals {
+			start := pos + strings.Index(v, strings.TrimSpace(v))
+			pos += len(v) + 1
+			p := strings.TrimSpace(v)
+			if !sqlPlaceholder.MatchString(p) || sqlPlaceholder.FindString(p) != p {
+				continue
+			}
+			names[key(p, start)] = column(cols[i])
+		}
+	}
+	return names
+}
+
+// sqlArg formats an argument value.
+func sqlArg(v driver.Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return fmt.Sprintf("<%d bytes>", len(v))
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
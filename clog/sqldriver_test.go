@@ -0,0 +1,111 @@
+package clog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeConnector opens fakeConns. Queries containing "fail" fail, those
+// containing "slow" sleep first.
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+// fakeStmt only implements the deprecated methods, so the wrapper falls back
+// to them.
+type fakeStmt struct{ query string }
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.run(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(args)), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := s.run(); err != nil {
+		return nil, err
+	}
+	return &fakeRows{}, nil
+}
+
+func (s fakeStmt) run() error {
+	if strings.Contains(s.query, "slow") {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if strings.Contains(s.query, "fail") {
+		return errors.New("relation does not exist")
+	}
+	return nil
+}
+
+type fakeRows struct{ done bool }
+
+func (r *fakeRows) Columns() []string { return []string{"n"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func TestWrapConnector(t *testing.T) {
+	buf := withTestLogger(t)
+	db := sql.OpenDB(WrapConnector(fakeConnector{}, SQLConfig{SlowThreshold: time.Millisecond, LogArgs: true}))
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), CTraceIDKey, "abc")
+
+	if _, err := db.ExecContext(ctx, "INSERT INTO users (name, pin) VALUES (?, ?)", "bob", "1234"); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRowContext(ctx, "SELECT n FROM slow WHERE password = $2 AND name = $1", "bob", "hunter2").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM fail"); err == nil {
+		t.Fatal("want an error")
+	}
+
+	lines := decodeLines(t, buf)
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	insert, query, failed := lines[0], lines[1], lines[2]
+	if insert["level"] != "debug" || insert[RowsField] != 2.0 || insert[TraceIDField] != "abc" {
+		t.Errorf("insert = %v", insert)
+	}
+	if args := insert[ArgsField].([]interface{}); args[0] != "bob" || args[1] != RedactedValue {
+		t.Errorf("insert args = %v", args)
+	}
+	if query["level"] != "warn" {
+		t.Errorf("query = %v, want a slow query warning", query)
+	}
+	if args := query[ArgsField].([]interface{}); args[0] != "bob" || args[1] != RedactedValue {
+		t.Errorf("query args = %v", args)
+	}
+	if failed["level"] != "error" || failed["error"] != "relation does not exist" {
+		t.Errorf("failed = %v", failed)
+	}
+	if strings.Contains(buf.String(), "1234") || strings.Contains(buf.String(), "hunter2") {
+		t.Error("redacted argument logged")
+	}
+}
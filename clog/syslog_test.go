@@ -0,0 +1,124 @@
+package clog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSyslogWriter_Format(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	w, err := NewSyslogWriter(SyslogConfig{
+		Addr:       pc.LocalAddr().String(),
+		Facility:   FacilityLocal0,
+		Hostname:   "node 1",
+		AppName:    "wallet",
+		MsgIDField: "method",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write([]byte(`{"level":"error","time":"2024-01-02T09:00:00Z","method":"/wallet.Wallet/Debit","trace":"abc","note":"a \"b\" ]","message":"debit failed"}`))
+
+	got := string(readDatagrams(t, pc, 1)[0])
+	want := "<131>1 2024-01-02T09:00:00.000000Z node_1 wallet " + strconv.Itoa(os.Getpid()) +
+		` /wallet.Wallet/Debit [clog@32473 note="a \"b\" \]" trace="abc"] debit failed`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	tests := map[zerolog.Level]int{
+		zerolog.TraceLevel: 7,
+		zerolog.DebugLevel: 7,
+		zerolog.InfoLevel:  6,
+		zerolog.NoLevel:    6,
+		zerolog.WarnLevel:  4,
+		zerolog.ErrorLevel: 3,
+		zerolog.FatalLevel: 2,
+		zerolog.PanicLevel: 0,
+	}
+	for level, want := range tests {
+		if got := syslogSeverity(level); got != want {
+			t.Errorf("syslogSeverity(%s) = %d, want %d", level, got, want)
+		}
+	}
+}
+
+func TestSyslogWriter_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	w, err := NewSyslogWriter(SyslogConfig{Network: "tcp", Addr: ln.Addr().String(), AppName: "wallet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	lg := zerolog.New(w)
+	lg.Info().Msg("one")
+	lg.Debug().Str("k", "v").Msg("two")
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, want := range []string{`<14>1 `, `<15>1 `} {
+		size, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil {
+			t.Fatalf("bad octet count %q", size)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(msg), want) {
+			t.Errorf("message %q does not start with %q", msg, want)
+		}
+	}
+}
+
+func TestSyslogWriter_Unixgram(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "log.sock")
+	pc, err := net.ListenPacket("unixgram", addr)
+	if err != nil {
+		t.Skipf("unixgram not supported: %s", err)
+	}
+	defer pc.Close()
+
+	w, err := NewSyslogWriter(SyslogConfig{Network: "unixgram", Addr: addr, AppName: "wallet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write([]byte(`{"level":"warn","message":"hello"}`))
+
+	got := string(readDatagrams(t, pc, 1)[0])
+	if !strings.HasPrefix(got, "<12>1 ") || !strings.HasSuffix(got, " wallet "+strconv.Itoa(os.Getpid())+" - - hello") {
+		t.Errorf("got %q", got)
+	}
+}
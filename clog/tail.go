@@ -0,0 +1,103 @@
+package clog
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ensure we always implement zerolog.LevelWriter
+var _ zerolog.LevelWriter = (*tailBuffer)(nil)
+
+var (
+	// TailBufferLog holds the debug and info events logged through the
+	// request logger in memory, writing them only when the request fails or
+	// is slower than TailBufferSlowThreshold. Debug events are captured even
+	// when debug logging is off, unless zerolog.GlobalLevel is above debug.
+	TailBufferLog = false
+	// TailBufferMaxEvents held per request, the oldest are dropped first.
+	TailBufferMaxEvents = 256
+	// TailBufferSlowThreshold flushes the events of requests slower than it.
+	// Zero only flushes failed requests.
+	TailBufferSlowThreshold time.Duration
+	// TailDroppedField key.
+	TailDroppedField = "dropped"
+)
+
+// tailBuffer holds the debug and info events of a request until it ends.
+// Events of higher levels are written through.
+type tailBuffer struct {
+	out zerolog.LevelWriter
+	max int
+
+	mu      sync.Mutex
+	events  [][]byte
+	levels  []zerolog.Level
+	dropped int
+}
+
+// tailLogger returns a logger holding the debug and info events of log, a
+// logger of l, in a tail buffer. It returns log and a nil buffer when tail
+// buffering is off.
+func (l *Logger) tailLogger(log *zerolog.Logger) (*zerolog.Logger, *tailBuffer) {
+	if !TailBufferLog {
+		return log, nil
+	}
+
+	b := &tailBuffer{out: l.out, max: TailBufferMaxEvents}
+	tailLog := new(zerolog.Logger)
+	*tailLog = log.Output(b).Level(zerolog.TraceLevel)
+	return tailLog, b
+}
+
+// Write implements io.Writer, taking the level from the event.
+func (b *tailBuffer) Write(p []byte) (n int, err error) {
+	return b.WriteLevel(eventLevel(p), p)
+}
+
+// WriteLevel implements zerolog.LevelWriter.
+func (b *tailBuffer) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
+	if level > zerolog.InfoLevel {
+		return b.out.WriteLevel(level, p)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.max <= 0 {
+		b.dropped++
+		return len(p), nil
+	}
+	if len(b.events) >= b.max {
+		b.events = b.events[1:]
+		b.levels = b.levels[1:]
+		b.dropped++
+	}
+	// zerolog reuses p once written
+	b.events = append(b.events, append([]byte(nil), p...))
+	b.levels = append(b.levels, level)
+	return len(p), nil
+}
+
+// finish writes the held events when the request failed or took longer than
+// TailBufferSlowThreshold, and drops them otherwise. Dropped events are
+// reported through log.
+func (b *tailBuffer) finish(log *zerolog.Logger, failed bool, dur time.Duration) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	slow := TailBufferSlowThreshold > 0 && dur > TailBufferSlowThreshold
+	if failed || slow {
+		if b.dropped > 0 {
+			log.Warn().Int(TailDroppedField, b.dropped).Msg("tail buffer full, oldest events dropped")
+		}
+		for i, p := range b.events {
+			// what am I going to do, log this?
+			_, _ = b.out.WriteLevel(b.levels[i], p)
+		}
+	}
+	b.events, b.levels, b.dropped = nil, nil, 0
+}
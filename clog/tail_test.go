@@ -0,0 +1,134 @@
+package clog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func withTailBuffer(t *testing.T, max int, slow time.Duration) {
+	t.Helper()
+	prevLog, prevMax, prevSlow := TailBufferLog, TailBufferMaxEvents, TailBufferSlowThreshold
+	TailBufferLog, TailBufferMaxEvents, TailBufferSlowThreshold = true, max, slow
+	t.Cleanup(func() {
+		TailBufferLog, TailBufferMaxEvents, TailBufferSlowThreshold = prevLog, prevMax, prevSlow
+	})
+}
+
+func TestUnaryServerInterceptor_TailBuffer(t *testing.T) {
+	buf := withTestLogger(t)
+	withTailBuffer(t, 2, time.Hour)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+	call := func(fail bool) []map[string]interface{} {
+		buf.Reset()
+		ctx := peerContext("127.0.0.1:4000", metadata.MD{})
+		UnaryServerInterceptorWithLogger()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			log := GetContextLog(ctx)
+			log.Debug().Msg("one")
+			log.Info().Msg("two")
+			log.Debug().Msg("three")
+			log.Warn().Msg("warn")
+			if fail {
+				return nil, errors.New("boom")
+			}
+			return nil, nil
+		})
+		return decodeLines(t, buf)
+	}
+
+	var msgs []interface{}
+	for _, m := range call(false) {
+		msgs = append(msgs, m["message"])
+	}
+	if len(msgs) != 3 || msgs[1] != "warn" {
+		t.Errorf("success: got %v, want request, warn and response lines", msgs)
+	}
+
+	lines := call(true)
+	msgs = nil
+	for _, m := range lines {
+		msgs = append(msgs, m["message"])
+		if m[TraceIDField] != lines[0][TraceIDField] {
+			t.Errorf("line %v has another trace ID than %v", m, lines[0][TraceIDField])
+		}
+	}
+	want := []interface{}{nil, "warn", "tail buffer full, oldest events dropped", "two", "three", nil}
+	if len(msgs) != len(want) {
+		t.Fatalf("failure: got %v, want %v", msgs, want)
+	}
+	for i := range want {
+		if msgs[i] != want[i] {
+			t.Errorf("failure line %d = %v, want %v", i, msgs[i], want[i])
+		}
+	}
+	if lines[2][TailDroppedField] != 1.0 {
+		t.Errorf("dropped = %v, want 1", lines[2][TailDroppedField])
+	}
+}
+
+func TestTraceLoggingMiddleware_TailBuffer(t *testing.T) {
+	buf := withTestLogger(t)
+	withTailBuffer(t, 10, time.Millisecond)
+
+	app := fiber.New()
+	app.Use(TraceLoggingMiddleware())
+	app.Get("/fast", func(c *fiber.Ctx) error {
+		GetContextLog(c.UserContext()).Debug().Msg("detail")
+		return c.SendString("ok")
+	})
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		GetContextLog(c.UserContext()).Debug().Msg("detail")
+		time.Sleep(5 * time.Millisecond)
+		return c.SendString("ok")
+	})
+
+	for path, want := range map[string]int{"/fast": 1, "/slow": 2} {
+		buf.Reset()
+		if _, err := app.Test(httptest.NewRequest("GET", path, nil)); err != nil {
+			t.Fatal(err)
+		}
+		lines := decodeLines(t, buf)
+		if len(lines) != want {
+			t.Errorf("%s: got %d lines, want %d", path, len(lines), want)
+			continue
+		}
+		access := lines[len(lines)-1]
+		if access[PathField] != path || access[StatusField] != 200.0 {
+			t.Errorf("%s: access line = %v", path, access)
+		}
+	}
+}
+
+func TestNewWithOptions_TailBufferKeepsGlobalLevel(t *testing.T) {
+	defer SetDefault(Default())
+	withTailBuffer(t, 10, time.Hour)
+
+	var buf bytes.Buffer
+	prev := zerolog.GlobalLevel()
+	if err := NewWithOptions(Options{Format: FormatJson, Sinks: []io.Writer{&buf}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := zerolog.GlobalLevel(); got != prev {
+		t.Fatalf("global level = %v, want %v left alone", got, prev)
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Service/Method"}
+	UnaryServerInterceptorWithLogger()(peerContext("127.0.0.1:4000", metadata.MD{}), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		GetContextLog(ctx).Debug().Msg("held")
+		return nil, errors.New("boom")
+	})
+	if !strings.Contains(buf.String(), `"message":"held"`) {
+		t.Errorf("output = %s, want the debug event of the failed request", buf.Bytes())
+	}
+}
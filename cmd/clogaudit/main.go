@@ -0,0 +1,67 @@
+// Command clogaudit verifies the hash chain of audit logs written by
+// clog.AuditWriter.
+//
+//	clogaudit -dir ./logs/audit
+//	clogaudit ./logs/audit/2024010215_1704186000.log.gz ./logs/audit/2024010216_latest.log
+//
+// Files given as arguments are checked in the given order. With -dir, every
+// log file of the directory is checked, ordered by its first sequence number.
+// The exit status is 1 when the chain is broken.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/wawafc/go-utils/clog"
+)
+
+func main() {
+	dir := flag.String("dir", "", "audit log directory")
+	keyFile := flag.String("keys", "", "key file (id=hex lines) of encrypted logs")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: clogaudit [-keys file] [-dir path] [file ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var keys clog.KeyProvider
+	if *keyFile != "" {
+		static, err := clog.LoadStaticKeys(*keyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "clogaudit: %s\n", err)
+			os.Exit(2)
+		}
+		keys = static
+	}
+
+	var (
+		report clog.AuditReport
+		err    error
+	)
+	switch {
+	case *dir != "" && flag.NArg() == 0:
+		report, err = clog.VerifyAuditDir(*dir, keys)
+	case *dir == "" && flag.NArg() > 0:
+		report, err = clog.VerifyAuditKeys(keys, flag.Args()...)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clogaudit: %s\n", err)
+		os.Exit(2)
+	}
+
+	for _, issue := range report.Issues {
+		fmt.Println(issue)
+	}
+	fmt.Printf("%d files, %d lines, seq %d..%d, last hash %s\n",
+		report.Files, report.Records, report.FirstSeq, report.LastSeq, report.LastHash)
+	if !report.OK() {
+		fmt.Printf("FAILED: %d issues\n", len(report.Issues))
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
@@ -0,0 +1,61 @@
+// Command clogcat prints log files written by the clog file sink, decrypting
+// encrypted files and decompressing gzipped backups.
+//
+//	clogcat -keys ./log.keys ./logs/202401021504_latest.log ./logs/20240102_1704186000.log.gz
+//
+// The key file holds "id=hex key" lines, see clog.LoadStaticKeys.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wawafc/go-utils/clog"
+)
+
+func main() {
+	keyFile := flag.String("keys", "", "key file (id=hex lines) of encrypted logs")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: clogcat [-keys file] file ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var keys clog.KeyProvider
+	if *keyFile != "" {
+		static, err := clog.LoadStaticKeys(*keyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "clogcat: %s\n", err)
+			os.Exit(2)
+		}
+		keys = static
+	}
+
+	status := 0
+	for _, name := range flag.Args() {
+		if err := cat(name, keys); err != nil {
+			fmt.Fprintf(os.Stderr, "clogcat: %s\n", err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func cat(name string, keys clog.KeyProvider) error {
+	rc, err := clog.OpenLogFile(name, keys)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if _, err := io.Copy(os.Stdout, rc); err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+// Command clogctl maintains the log directories written by the clog file
+// sink, for instance on a host where the service is not running.
+//
+//	clogctl list ./logs
+//	clogctl mill -max-age 5 -compress -dry-run ./logs
+//	clogctl verify ./logs
+//
+// It uses clog's own naming and mill rules, so its results always match
+// what the library does after a rotation.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wawafc/go-utils/clog"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "list":
+		err = list(os.Args[2:])
+	case "mill":
+		err = mill(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "clogctl: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: clogctl <command> [flags] dir
+
+commands:
+  list    list log files with their time key ranges and sizes
+  mill    compress and remove backups like the file sink does after a rotation
+  verify  check the integrity of gzipped and encrypted log files
+
+Run clogctl <command> -h for the flags of a command.
+`)
+	os.Exit(2)
+}
+
+func list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	timeKey := fs.String("timekey", "200601021504", "time key layout of the log file names")
+	tz := fs.String("tz", "Asia/Bangkok", "time zone of the time keys")
+	dir := parseDir(fs, args)
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	files, err := clog.ListLogFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tFROM\tTO\tROTATED\tSIZE\tSTATE")
+	var total int64
+	for _, f := range files {
+		from, to := "-", "-"
+		if start, end, err := f.KeyRange(*timeKey, loc); err == nil {
+			from, to = start.Format(time.RFC3339), end.Format(time.RFC3339)
+		}
+		rotated := "-"
+		if f.Backup() {
+			rotated = f.Rotated.In(loc).Format(time.RFC3339)
+		}
+		state := "backup"
+		switch {
+		case f.Latest:
+			state = "latest"
+		case f.Compressed:
+			state = "compressed"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", f.Path, from, to, rotated, humanSize(f.Size), state)
+		total += f.Size
+	}
+	fmt.Fprintf(tw, "%d files\t\t\t\t%s\t\n", len(files), humanSize(total))
+	return tw.Flush()
+}
+
+func mill(args []string) error {
+	fs := flag.NewFlagSet("mill", flag.ExitOnError)
+	maxAge := fs.Int("max-age", 0, "days to keep backups, 0 keeps them all")
+	maxBackups := fs.Int("max-backups", 0, "number of backups to keep, 0 keeps them all")
+	compress := fs.Bool("compress", false, "gzip backups")
+	filename := fs.String("filename", "", "log file name of a Filename sink, e.g. app.log; empty for a time key directory")
+	timeKey := fs.String("timekey", "200601021504", "time key layout of the log file names")
+	tz := fs.String("tz", "Asia/Bangkok", "time zone of the time keys")
+	template := fs.String("template", "", "file name template of the sink, e.g. {service}-{time}")
+	service := fs.String("service", "", "service of the {service} token of -template")
+	keyFile := fs.String("keys", "", "key file (id=hex lines) of encrypted logs")
+	dryRun := fs.Bool("dry-run", false, "only print what would be done")
+	dir := parseDir(fs, args)
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	cf := clog.ConfigFile{
+		EnableTimeKey:    *filename == "",
+		TimeKey:          *timeKey,
+		TImeZone:         loc,
+		Path:             dir,
+		MaxAge:           *maxAge,
+		MaxBackups:       *maxBackups,
+		Compress:         *compress,
+		FilenameTemplate: *template,
+		Service:          *service,
+	}
+	if *filename != "" {
+		cf.Filename = filepath.Join(dir, *filename)
+	}
+	if *keyFile != "" {
+		keys, err := clog.LoadStaticKeys(*keyFile)
+		if err != nil {
+			return err
+		}
+		cf.KeyProvider = keys
+	}
+
+	var plan clog.MillPlan
+	if *dryRun {
+		plan, err = clog.PlanMill(cf)
+	} else {
+		plan, err = clog.RunMill(cf)
+	}
+
+	prefix := ""
+	if *dryRun {
+		prefix = "would "
+	}
+	for _, f := range plan.Remove {
+		fmt.Printf("%sremove %s\n", prefix, f.Path)
+	}
+	for _, f := range plan.Compress {
+		fmt.Printf("%scompress %s\n", prefix, f.Path)
+	}
+	return err
+}
+
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyFile := fs.String("keys", "", "key file (id=hex lines) of encrypted logs")
+	dir := parseDir(fs, args)
+
+	var keys clog.KeyProvider
+	if *keyFile != "" {
+		static, err := clog.LoadStaticKeys(*keyFile)
+		if err != nil {
+			return err
+		}
+		keys = static
+	}
+
+	files, err := clog.ListLogFiles(dir)
+	if err != nil {
+		return err
+	}
+	var bad int
+	for _, f := range files {
+		if err := clog.CheckLogFile(f.Path, keys); err != nil {
+			fmt.Printf("BAD %s: %s\n", f.Path, err)
+			bad++
+		}
+	}
+	fmt.Printf("%d files checked, %d bad\n", len(files), bad)
+	if bad > 0 {
+		return fmt.Errorf("%d corrupt files", bad)
+	}
+	return nil
+}
+
+// parseDir parses the flags of a command and returns its directory argument.
+func parseDir(fs *flag.FlagSet, args []string) string {
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: clogctl %s [flags] dir\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return fs.Arg(0)
+}
+
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
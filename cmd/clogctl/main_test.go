@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestMill_TimeKeyDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"2024010215_1704182400.log",
+		"2024010215_1704182460.log",
+		"2024010216_1704186000.log",
+		"2024010217_latest.log",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(`{"message":"x"}`+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mill([]string{"-timekey", "2006010215", "-tz", "UTC", "-max-backups", "2", "-compress", dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	sort.Strings(got)
+	want := []string{"2024010215_1704182460.log.gz", "2024010216_1704186000.log.gz", "2024010217_latest.log"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("after mill = %v, want %v", got, want)
+	}
+}
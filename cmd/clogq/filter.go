@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/wawafc/go-utils/clog"
+)
+
+// query selects log events.
+type query struct {
+	since, until time.Time
+	level        zerolog.Level // NoLevel matches every level
+	traceID      string
+	service      string
+	method       string
+	where        []expr
+}
+
+func (q *query) match(evt map[string]interface{}, t time.Time) bool {
+	if !q.since.IsZero() && t.Before(q.since) {
+		return false
+	}
+	if !q.until.IsZero() && !t.Before(q.until) {
+		return false
+	}
+	if q.level != zerolog.NoLevel {
+		lvl, err := zerolog.ParseLevel(fmt.Sprint(evt[zerolog.LevelFieldName]))
+		if err != nil || lvl < q.level {
+			return false
+		}
+	}
+	if q.traceID != "" && fmt.Sprint(lookup(evt, clog.TraceIDField)) != q.traceID {
+		return false
+	}
+	if q.service != "" && fmt.Sprint(lookup(evt, clog.ServiceField)) != q.service {
+		return false
+	}
+	if q.method != "" && fmt.Sprint(lookup(evt, clog.MethodField)) != q.method {
+		return false
+	}
+	for _, e := range q.where {
+		if !e.match(evt) {
+			return false
+		}
+	}
+	return true
+}
+
+// expr is a field expression such as code!=OK, dur>250 or msg~timeout.
+type expr struct {
+	key   string
+	op    string
+	value string
+	num   float64
+	re    *regexp.Regexp
+}
+
+// exprOps are tried in order, so two-character operators come first.
+var exprOps = []string{"!=", ">=", "<=", "!~", "=", "~", ">", "<"}
+
+func parseExpr(s string) (expr, error) {
+	for _, op := range exprOps {
+		i := strings.Index(s, op)
+		if i <= 0 {
+			continue
+		}
+		e := expr{key: s[:i], op: op, value: s[i+len(op):]}
+		switch op {
+		case "~", "!~":
+			re, err := regexp.Compile(e.value)
+			if err != nil {
+				return expr{}, fmt.Errorf("%s: %s", s, err)
+			}
+			e.re = re
+		case ">", "<", ">=", "<=":
+			num, err := strconv.ParseFloat(e.value, 64)
+			if err != nil {
+				return expr{}, fmt.Errorf("%s: %s is not a number", s, e.value)
+			}
+			e.num = num
+		}
+		return e, nil
+	}
+	return expr{}, fmt.Errorf("%s: expected key=value, key!=value, key~regexp, key!~regexp or a numeric comparison", s)
+}
+
+func (e expr) match(evt map[string]interface{}) bool {
+	v := lookup(evt, e.key)
+	if v == nil {
+		return e.op == "!=" || e.op == "!~"
+	}
+	s := stringify(v)
+	switch e.op {
+	case "=":
+		return s == e.value
+	case "!=":
+		return s != e.value
+	case "~":
+		return e.re.MatchString(s)
+	case "!~":
+		return !e.re.MatchString(s)
+	}
+
+	num, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false
+	}
+	switch e.op {
+	case ">":
+		return num > e.num
+	case "<":
+		return num < e.num
+	case ">=":
+		return num >= e.num
+	default:
+		return num <= e.num
+	}
+}
+
+// lookup returns the field key of evt, following dots into nested objects
+// when no field has the full key.
+func lookup(evt map[string]interface{}, key string) interface{} {
+	if v, ok := evt[key]; ok {
+		return v
+	}
+	head, rest, ok := strings.Cut(key, ".")
+	if !ok {
+		return nil
+	}
+	if nested, ok := evt[head].(map[string]interface{}); ok {
+		return lookup(nested, rest)
+	}
+	return nil
+}
+
+func stringify(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case json.Number:
+		return vv.String()
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(vv)
+		return string(b)
+	default:
+		return fmt.Sprint(vv)
+	}
+}
+
+// eventTime reads the timestamp of evt. Numbers are Unix time in seconds,
+// milliseconds, microseconds or nanoseconds, told apart by magnitude.
+func eventTime(evt map[string]interface{}) (time.Time, bool) {
+	switch v := evt[zerolog.TimestampFieldName].(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			switch {
+			case i > 1e17:
+				return time.Unix(0, i), true
+			case i > 1e14:
+				return time.UnixMicro(i), true
+			case i > 1e11:
+				return time.UnixMilli(i), true
+			default:
+				return time.Unix(i, 0), true
+			}
+		}
+		if f, err := v.Float64(); err == nil {
+			return time.Unix(0, int64(f*float64(time.Second))), true
+		}
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, zerolog.TimeFieldFormat} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseTimeFlag parses an RFC 3339 time, or a duration before now.
+func parseTimeFlag(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or a duration such as 2h", s)
+}
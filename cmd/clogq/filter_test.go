@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExpr(t *testing.T) {
+	var evt map[string]interface{}
+	d := json.NewDecoder(strings.NewReader(`{"code":"OK","dur":12.5,"md":{"user-agent":"grpc-go/1.50"}}`))
+	d.UseNumber()
+	if err := d.Decode(&evt); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"code=OK", true},
+		{"code!=OK", false},
+		{"missing!=OK", true},
+		{"dur>10", true},
+		{"dur<=12.5", true},
+		{"dur<12", false},
+		{"md.user-agent~^grpc-go", true},
+		{"md.user-agent!~grpc", false},
+	}
+	for _, c := range cases {
+		e, err := parseExpr(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		if got := e.match(evt); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.expr, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"code", "dur>ten", "msg~("} {
+		if _, err := parseExpr(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
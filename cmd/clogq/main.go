@@ -0,0 +1,199 @@
+// Command clogq searches the log files written by the clog file sink, across
+// rotated, gzipped and encrypted backups, and prints the matching events in
+// time order.
+//
+//	clogq -trace 9f86d081 ./logs
+//	clogq -since 2h -level warn -service pkg.Wallet -where 'code!=OK' ./logs
+//	clogq -since 2024-01-02T15:00:00+07:00 -until 2024-01-02T16:00:00+07:00 -o pretty
+//
+// Arguments are log directories or files, ./logs by default. -where may be
+// given several times; its expressions are key=value, key!=value,
+// key~regexp, key!~regexp or a numeric comparison such as dur>250. Nested
+// fields are addressed with dots, e.g. md.user-agent.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/wawafc/go-utils/clog"
+)
+
+type multiFlag []string
+
+func (m *multiFlag) String() string     { return strings.Join(*m, ", ") }
+func (m *multiFlag) Set(s string) error { *m = append(*m, s); return nil }
+
+func main() {
+	var where multiFlag
+	since := flag.String("since", "", "events at or after this time (RFC 3339, or a duration before now such as 2h)")
+	until := flag.String("until", "", "events before this time (RFC 3339, or a duration before now)")
+	level := flag.String("level", "", "minimum level (trace, debug, info, warn, error, fatal, panic)")
+	traceID := flag.String("trace", "", "trace ID")
+	service := flag.String("service", "", "gRPC service")
+	method := flag.String("method", "", "gRPC method")
+	flag.Var(&where, "where", "field expression, may be repeated")
+	output := flag.String("o", "json", "output format: json or pretty")
+	keyFile := flag.String("keys", "", "key file (id=hex lines) of encrypted logs")
+	timeKey := flag.String("timekey", "200601021504", "time key layout of the log file names")
+	tz := flag.String("tz", "Asia/Bangkok", "time zone of the time keys")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: clogq [flags] [dir or file ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	q, err := buildQuery(*since, *until, *level, *traceID, *service, *method, where)
+	if err != nil {
+		fail(err)
+	}
+
+	var keys clog.KeyProvider
+	if *keyFile != "" {
+		static, err := clog.LoadStaticKeys(*keyFile)
+		if err != nil {
+			fail(err)
+		}
+		keys = static
+	}
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		fail(err)
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"./logs"}
+	}
+	names, err := selectFiles(args, q, *timeKey, loc)
+	if err != nil {
+		fail(err)
+	}
+
+	var sources []*source
+	for _, name := range names {
+		s, err := openSource(name, len(sources), keys)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "clogq: %s\n", err)
+			continue
+		}
+		defer s.rc.Close()
+		sources = append(sources, s)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	var w io.Writer = out
+	switch *output {
+	case "json":
+	case "pretty":
+		// clog's JSON formats write Unix timestamps
+		zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+		w = zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339}
+	default:
+		fail(fmt.Errorf("unknown output format %q", *output))
+	}
+
+	err = merge(sources, func(e *event) error {
+		if !q.match(e.fields, e.time) {
+			return nil
+		}
+		_, err := w.Write(append(e.line, '\n'))
+		return err
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	for _, s := range sources {
+		if s.err != nil {
+			fmt.Fprintf(os.Stderr, "clogq: %s: %s\n", s.name, s.err)
+		}
+		if s.errors > 0 {
+			fmt.Fprintf(os.Stderr, "clogq: %s: skipped %d lines that are not JSON\n", s.name, s.errors)
+		}
+	}
+}
+
+func buildQuery(since, until, level, traceID, service, method string, where []string) (*query, error) {
+	now := time.Now()
+	q := &query{traceID: traceID, service: service, method: method, level: zerolog.NoLevel}
+
+	var err error
+	if q.since, err = parseTimeFlag(since, now); err != nil {
+		return nil, err
+	}
+	if q.until, err = parseTimeFlag(until, now); err != nil {
+		return nil, err
+	}
+	if level != "" {
+		if q.level, err = zerolog.ParseLevel(level); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range where {
+		e, err := parseExpr(s)
+		if err != nil {
+			return nil, err
+		}
+		q.where = append(q.where, e)
+	}
+	return q, nil
+}
+
+// selectFiles expands directories to their log files, leaving out the files
+// that cannot hold events of the query's time range.
+func selectFiles(args []string, q *query, timeKey string, loc *time.Location) ([]string, error) {
+	var names []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			names = append(names, arg)
+			continue
+		}
+
+		files, err := clog.ListLogFiles(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if inRange(f, q, timeKey, loc) {
+				names = append(names, f.Path)
+			}
+		}
+	}
+	return names, nil
+}
+
+// inRange reports whether f may hold events between q.since and q.until.
+func inRange(f clog.LogFile, q *query, timeKey string, loc *time.Location) bool {
+	// a backup is rotated after its last event was written
+	if !q.since.IsZero() && f.Backup() && f.Rotated.Before(q.since.Add(-time.Second)) {
+		return false
+	}
+	start, end, err := f.KeyRange(timeKey, loc)
+	if err != nil {
+		return true
+	}
+	if !q.until.IsZero() && !start.Before(q.until) {
+		return false
+	}
+	if !q.since.IsZero() && !end.After(q.since) {
+		return false
+	}
+	return true
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "clogq: %s\n", err)
+	os.Exit(2)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/wawafc/go-utils/clog"
+)
+
+// event is one log line.
+type event struct {
+	line   []byte
+	fields map[string]interface{}
+	time   time.Time
+	src    int
+	seq    int
+}
+
+// source reads the events of one log file.
+type source struct {
+	name   string
+	rc     io.ReadCloser
+	br     *bufio.Reader
+	idx    int
+	seq    int
+	last   time.Time
+	errors int
+	err    error
+}
+
+func openSource(name string, idx int, keys clog.KeyProvider) (*source, error) {
+	rc, err := clog.OpenLogFile(name, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &source{name: name, rc: rc, br: bufio.NewReaderSize(rc, 64*1024), idx: idx}, nil
+}
+
+// next returns the next event of s, or io.EOF. Lines that are not JSON are
+// counted and skipped; lines without a timestamp take the time of the line
+// before them. A read error, such as a truncated gzip file, ends the source
+// and is kept in s.err.
+func (s *source) next() (*event, error) {
+	for {
+		line, err := s.br.ReadBytes('\n')
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) > 0 {
+			d := json.NewDecoder(bytes.NewReader(line))
+			d.UseNumber()
+			var fields map[string]interface{}
+			if errDecode := d.Decode(&fields); errDecode != nil {
+				s.errors++
+			} else {
+				t, ok := eventTime(fields)
+				if !ok {
+					t = s.last
+				}
+				s.last = t
+				s.seq++
+				return &event{line: line, fields: fields, time: t, src: s.idx, seq: s.seq}, nil
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			return nil, io.EOF
+		}
+	}
+}
+
+// mergeHeap orders the head events of the sources by time, then by file order
+// and line order so that equal timestamps keep their order on disk.
+type mergeHeap []*event
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if !h[i].time.Equal(h[j].time) {
+		return h[i].time.Before(h[j].time)
+	}
+	if h[i].src != h[j].src {
+		return h[i].src < h[j].src
+	}
+	return h[i].seq < h[j].seq
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*event)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// merge calls fn with the events of sources in time order. Each file is
+// expected to be in time order already.
+func merge(sources []*source, fn func(*event) error) error {
+	h := &mergeHeap{}
+	for _, s := range sources {
+		if e, err := s.next(); err == nil {
+			heap.Push(h, e)
+		}
+	}
+
+	for h.Len() > 0 {
+		e := heap.Pop(h).(*event)
+		if err := fn(e); err != nil {
+			return err
+		}
+		if next, err := sources[e.src].next(); err == nil {
+			heap.Push(h, next)
+		}
+	}
+	return nil
+}